@@ -6,6 +6,7 @@ import (
 
 	"github.com/NeowayLabs/klb/tests/lib/azure"
 	"github.com/NeowayLabs/klb/tests/lib/azure/fixture"
+	"github.com/NeowayLabs/klb/tests/lib/golden"
 )
 
 func TestLoadBalancer(t *testing.T) {
@@ -72,6 +73,7 @@ func testLoadBalancer(t *testing.T, f fixture.F) {
 		},
 	})
 
+	golden.New(t, "LoadBalancer").AssertResource(lbname, loadbalancer.Get(t, lbname))
 }
 
 func createLoadBalancer(
@@ -149,13 +151,13 @@ func createLoadBalancerRules(
 }
 
 func getLBAddressPoolID(t *testing.T, f fixture.F, lbname string, poolname string) string {
-	return execWithIPC(t, f, func(outfile string) {
-		f.Shell.Run(
-			"./testdata/alb_get_pool_id.sh",
-			poolname,
-			f.ResGroupName,
-			lbname,
-			outfile,
-		)
-	})
+	res := f.Shell.CallE(
+		"azure/lb",
+		"azure_lb_addresspool_get_id",
+		1,
+		poolname,
+		f.ResGroupName,
+		lbname,
+	)
+	return res[0][0]
 }
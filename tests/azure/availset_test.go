@@ -15,10 +15,11 @@ func genAvailSetName() string {
 
 func testAvailSetCreate(t *testing.T, f fixture.F) {
 	availset := genAvailSetName()
-	f.Shell.Run(
-		"./testdata/create_avail_set.sh",
-		f.ResGroupName,
+	f.Shell.Call(
+		"azure/availset",
+		"azure_availset_create",
 		availset,
+		f.ResGroupName,
 		f.Location,
 	)
 	availSets := azure.NewAvailSet(f)
@@ -27,20 +28,22 @@ func testAvailSetCreate(t *testing.T, f fixture.F) {
 
 func testAvailSetDelete(t *testing.T, f fixture.F) {
 	availset := genAvailSetName()
-	f.Shell.Run(
-		"./testdata/create_avail_set.sh",
-		f.ResGroupName,
+	f.Shell.Call(
+		"azure/availset",
+		"azure_availset_create",
 		availset,
+		f.ResGroupName,
 		f.Location,
 	)
 
 	availSets := azure.NewAvailSet(f)
 	availSets.AssertExists(t, availset)
 
-	f.Shell.Run(
-		"./testdata/delete_avail_set.sh",
-		f.ResGroupName,
+	f.Shell.Call(
+		"azure/availset",
+		"azure_availset_delete",
 		availset,
+		f.ResGroupName,
 	)
 	availSets.AssertDeleted(t, availset)
 }
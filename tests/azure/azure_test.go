@@ -1,8 +1,111 @@
 package azure_test
 
-import "time"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/azure/fixture"
+	"github.com/NeowayLabs/klb/tests/lib/golden"
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+	"github.com/NeowayLabs/klb/tests/lib/ratelimit"
+	"github.com/NeowayLabs/klb/tests/lib/report"
+	"github.com/NeowayLabs/klb/tests/lib/telemetry"
+)
 
 const (
 	location = "eastus2"
 	timeout  = 30 * time.Minute
 )
+
+// locations are where matrix tests run by default.
+// WHY: each location multiplies the duration of matrix runs, like
+// the VM ones, so only one is used. Use -locations to run on more
+// of the locations klb is used, like "eastus2,westus2".
+var locations = []string{location}
+
+var klbcoverage = flag.String(
+	"klbcoverage",
+	"",
+	"save a report of the klb functions called by the tests on the given path",
+)
+
+var telemetryPath = flag.String(
+	"telemetry",
+	"",
+	"save the retries and durations of operations and scripts on the given path",
+)
+
+var junitReport = flag.String(
+	"junit",
+	"",
+	"save a JUnit XML report of the fixture tests on the given path",
+)
+
+var htmlReport = flag.String(
+	"htmlreport",
+	"",
+	"save an HTML report of the fixture tests on the given path",
+)
+
+func TestMain(m *testing.M) {
+	testlog.RegisterFlags(flag.CommandLine)
+	golden.RegisterFlags(flag.CommandLine)
+	fixture.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if *klbcoverage != "" {
+		err := nash.EnableCoverage()
+		if err != nil {
+			fmt.Printf("error enabling klb coverage: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// WHY: the output is only needed by the reports
+	restoreStdout := func() error { return nil }
+	if *junitReport != "" || *htmlReport != "" {
+		var err error
+		restoreStdout, err = report.Capture()
+		if err != nil {
+			fmt.Printf("error capturing test output: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	code := m.Run()
+	err := restoreStdout()
+	if err != nil {
+		fmt.Printf("error capturing test output: %s\n", err)
+		os.Exit(1)
+	}
+
+	ratelimit.Subscription.Summary(os.Stdout)
+
+	if *klbcoverage != "" {
+		summary, err := nash.SaveCoverageReport(*klbcoverage)
+		if err != nil {
+			fmt.Printf("error saving klb coverage report: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("klb coverage: %s, report saved at %s\n", summary, *klbcoverage)
+	}
+
+	err = telemetry.Save(*telemetryPath, os.Stdout)
+	if err != nil {
+		fmt.Printf("error saving telemetry: %s\n", err)
+		os.Exit(1)
+	}
+	if *telemetryPath != "" {
+		fmt.Printf("telemetry saved at %s\n", *telemetryPath)
+	}
+
+	err = report.Save(*junitReport, *htmlReport)
+	if err != nil {
+		fmt.Printf("error saving reports: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
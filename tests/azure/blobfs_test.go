@@ -1,7 +1,6 @@
 package azure_test
 
 import (
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -27,11 +26,7 @@ func TestBlobFS(t *testing.T) {
 	testBlobFSUploadDir(t, timeout, location)
 	testBlobFSListFiles(t, timeout, location)
 	testBlobFSListDirs(t, timeout, location)
-	testBlobFSCreatesAccountAndContainerIfNonExistent(
-		t,
-		timeout,
-		location,
-	)
+	testBlobFSCreatesAccountAndContainerIfNonExistent(t, timeout)
 }
 
 func testBlobFSUploadsWhenAccountAndContainerExists(t *testing.T, f fixture.F) {
@@ -56,13 +51,13 @@ func testBlobFSUploadsWhenAccountAndContainerExists(t *testing.T, f fixture.F) {
 	assert.EqualStrings(t, sf.testfileContent, filecontent, "checking uploaded BLOB")
 }
 
-func blobFSCreatesAccountAndContainerIfNonExistent(
-	t *testing.T,
-	f fixture.F,
-	sku string,
-	tier string,
-) {
-	sf, cleanup := setupBlobStorageFixture(t, f, sku, tier)
+func blobFSCreatesAccountAndContainerIfNonExistent(t *testing.T, f fixture.F) {
+	sf, cleanup := setupBlobStorageFixture(
+		t,
+		f,
+		f.Combination.StorageSKU,
+		f.Combination.AccessTier,
+	)
 	defer cleanup()
 
 	expectedPath := "/test/acc/container/nonexistent/file"
@@ -75,57 +70,22 @@ func blobFSCreatesAccountAndContainerIfNonExistent(
 	assert.EqualStrings(t, sf.testfileContent, filecontent, "checking uploaded BLOB")
 }
 
-func testBlobFSCreatesAccountAndContainerIfNonExistent(
-	t *testing.T,
-	timeout time.Duration,
-	location string,
-) {
-	type TestCase struct {
-		sku  string
-		tier string
-	}
-
+func testBlobFSCreatesAccountAndContainerIfNonExistent(t *testing.T, timeout time.Duration) {
 	// WHY: lot of cases are not tested because they do not work on az cli
 	// like using Premium_LRS or Standard_ZRS as SKU.
 	// The docs are pretty confusing right now
 	// on how to use the SKU option when handling blob storage.
-	tcases := []TestCase{
-		TestCase{
-			sku:  "Standard_LRS",
-			tier: "Cool",
-		},
-		TestCase{
-			sku:  "Standard_LRS",
-			tier: "Hot",
-		},
-		TestCase{
-			sku:  "Standard_GRS",
-			tier: "Cool",
-		},
-		TestCase{
-			sku:  "Standard_GRS",
-			tier: "Hot",
-		},
-		TestCase{
-			sku:  "Standard_RAGRS",
-			tier: "Cool",
-		},
-		TestCase{
-			sku:  "Standard_RAGRS",
-			tier: "Hot",
+	fixture.RunMatrix(
+		t,
+		"CreatesAccountAndContainerIfNonExistent",
+		fixture.Matrix{
+			Locations:   locations,
+			StorageSKUs: []string{"Standard_LRS", "Standard_GRS", "Standard_RAGRS"},
+			AccessTiers: []string{"Cool", "Hot"},
 		},
-	}
-
-	for _, tcase := range tcases {
-		tname := fmt.Sprintf(
-			"CreatesAccountAndContainerIfNonExistent%s%s",
-			tcase.sku,
-			tcase.tier,
-		)
-		fixture.Run(t, tname, timeout, location, func(t *testing.T, f fixture.F) {
-			blobFSCreatesAccountAndContainerIfNonExistent(t, f, tcase.sku, tcase.tier)
-		})
-	}
+		timeout,
+		blobFSCreatesAccountAndContainerIfNonExistent,
+	)
 }
 
 func testBlobFSListDirs(t *testing.T, timeout time.Duration, location string) {
@@ -432,28 +392,7 @@ func testBlobFSList(
 		wantpaths []string,
 	) {
 		gotpaths := list(t, f, fs, remotedir)
-		if len(gotpaths) != len(wantpaths) {
-			t.Fatalf(
-				"want files[%s] size[%d] got[%s] size[%d]",
-				wantpaths,
-				len(wantpaths),
-				gotpaths,
-				len(gotpaths),
-			)
-		}
-
-		for _, wantpath := range wantpaths {
-			found := false
-			for _, gotfile := range gotpaths {
-				if wantpath == gotfile {
-					found = true
-					break
-				}
-			}
-			if !found {
-				t.Fatalf("unable to find [%s] on [%s]", wantpath, gotpaths)
-			}
-		}
+		assert.EqualStringSlicesUnordered(t, wantpaths, gotpaths, "listing dir %s", remotedir)
 	}
 
 	for _, _t := range tests {
@@ -502,38 +441,38 @@ func newBlobFS(
 	}
 }
 
+// new returns the klb blob fs instance
+func (fs *blobFS) new(f fixture.F) []string {
+	return f.Shell.CallStrings(
+		"azure/blob/fs",
+		"azure_blob_fs_new",
+		f.ResGroupName,
+		fs.account,
+		fs.container,
+		"60",
+	)
+}
+
 func (fs *blobFS) ListDir(t *testing.T, f fixture.F, remotedir string) []string {
-	res := execWithIPC(t, f, func(outputpath string) {
-		f.Shell.Run(
-			"./testdata/blob_fs_listdir.sh",
-			f.ResGroupName,
-			fs.account,
-			fs.container,
-			remotedir,
-			outputpath,
-		)
-	})
-	if res == "" {
-		return []string{}
-	}
-	return strings.Split(res, " ")
+	res := f.Shell.CallE(
+		"azure/blob/fs",
+		"azure_blob_fs_listdir",
+		1,
+		fs.new(f),
+		remotedir,
+	)
+	return res[0]
 }
 
 func (fs *blobFS) List(t *testing.T, f fixture.F, remotedir string) []string {
-	res := execWithIPC(t, f, func(outputpath string) {
-		f.Shell.Run(
-			"./testdata/blob_fs_list.sh",
-			f.ResGroupName,
-			fs.account,
-			fs.container,
-			remotedir,
-			outputpath,
-		)
-	})
-	if res == "" {
-		return []string{}
-	}
-	return strings.Split(res, " ")
+	res := f.Shell.CallE(
+		"azure/blob/fs",
+		"azure_blob_fs_list",
+		1,
+		fs.new(f),
+		remotedir,
+	)
+	return res[0]
 }
 
 func (fs *blobFS) Download(
@@ -541,14 +480,14 @@ func (fs *blobFS) Download(
 	f fixture.F,
 	remotefile string,
 ) string {
-	return execWithIPC(t, f, func(outputpath string) {
-		f.Shell.Run(
-			"./testdata/blob_fs_download.sh",
-			f.ResGroupName,
-			fs.account,
-			fs.container,
+	return downloadContents(t, func(localpath string) {
+		f.Shell.CallE(
+			"azure/blob/fs",
+			"azure_blob_fs_download",
+			0,
+			fs.new(f),
+			localpath,
 			remotefile,
-			outputpath,
 		)
 	})
 }
@@ -1,16 +1,20 @@
 package azure_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/NeowayLabs/klb/tests/lib/assert"
 	"github.com/NeowayLabs/klb/tests/lib/azure"
 	"github.com/NeowayLabs/klb/tests/lib/azure/fixture"
 )
 
 func TestNIC(t *testing.T) {
 	t.Parallel()
-	fixture.Run(t, "NICCreation", timeout, location, testNicCreate)
-	fixture.Run(
+	fixture.RunCassette(t, "NICCreation", timeout, location, testNicCreate)
+	fixture.RunCassette(
 		t,
 		"NICLoadBalancerAddressPoolIntegration",
 		timeout,
@@ -24,29 +28,13 @@ func assertLBBackendAddrPoolsOnNIC(
 	ipconfig azure.NicIPConfig,
 	expectedPools []string,
 ) {
-	if len(expectedPools) != len(ipconfig.LBBackendAddrPoolsIDs) {
-		t.Fatalf(
-			"expectedPools[%s] != gotPools[%s]",
-			expectedPools,
-			ipconfig.LBBackendAddrPoolsIDs,
-		)
-	}
-
-	for _, expected := range expectedPools {
-		got := false
-		for _, pool := range ipconfig.LBBackendAddrPoolsIDs {
-			if expected == pool {
-				got = true
-			}
-		}
-		if !got {
-			t.Fatalf(
-				"unable to find addrpool[%s] on [%s]",
-				expected,
-				ipconfig.LBBackendAddrPoolsIDs,
-			)
-		}
-	}
+	t.Helper()
+	assert.EqualStringSlicesUnordered(
+		t,
+		expectedPools,
+		ipconfig.LBBackendAddrPoolsIDs,
+		"checking NIC load balancer address pools",
+	)
 }
 
 func testNicLoadBalancerAddressPoolIntegration(t *testing.T, f fixture.F) {
@@ -88,10 +76,44 @@ func testNicLoadBalancerAddressPoolIntegration(t *testing.T, f fixture.F) {
 
 	ipconfig = getIPConfig(t, f, nic)
 	assertLBBackendAddrPoolsOnNIC(t, ipconfig, []string{poolID})
+	keepPoolFor := time.Minute
+	if f.Replaying {
+		// WHY: replayed responses never change, probing once is enough
+		keepPoolFor = 0
+	}
+	assert.Consistently(
+		f.Ctx,
+		t,
+		f.Retrier,
+		"NIC keeps the LB address pool",
+		keepPoolFor,
+		nicLBPoolsProbe(t, f, nic),
+		assert.IsUnordered([]string{poolID}),
+	)
 
 	removeLBAddressPoolFromNIC(t, f, nic, ipconfig.Name, poolID)
-	ipconfig = getIPConfig(t, f, nic)
-	assertLBBackendAddrPoolsOnNIC(t, ipconfig, []string{})
+	assert.Eventually(
+		f.Ctx,
+		t,
+		f.Retrier,
+		"NIC has no LB address pool",
+		nicLBPoolsProbe(t, f, nic),
+		assert.IsUnordered([]string{}),
+	)
+}
+
+func nicLBPoolsProbe(t *testing.T, f fixture.F, nic string) assert.Probe {
+	nics := azure.NewNic(f)
+	return func(context.Context) (interface{}, error) {
+		ipconfigs, err := nics.GetIPConfigs(t, nic)
+		if err != nil {
+			return nil, err
+		}
+		if len(ipconfigs) != 1 {
+			return nil, fmt.Errorf("expected one ipconfig, got: %v", ipconfigs)
+		}
+		return ipconfigs[0].LBBackendAddrPoolsIDs, nil
+	}
 }
 
 func testNicCreate(t *testing.T, f fixture.F) {
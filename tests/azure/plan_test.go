@@ -0,0 +1,49 @@
+package azure_test
+
+import (
+	"context"
+	"testing"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/plan"
+)
+
+// TestPlanExamples validates that changes on the examples do
+// what we expect, without running anything on the cloud.
+func TestPlanExamples(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logger, teardown := testlog.New(t, "TestPlanExamples")
+	defer teardown()
+
+	state, err := plan.LoadState("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := plan.Run(ctx, logger.Logger, nil, state, "../../examples/azure/vm/build.sh")
+	if err != nil {
+		t.Fatalf("error planning vm example: %s\nplan:\n%s", err, p)
+	}
+	logger.Printf("vm example plan:\n%s", p)
+
+	if len(p.Ops) == 0 {
+		t.Fatal("expected vm example to have ops")
+	}
+	first := p.Ops[0]
+	if first.Action != plan.Create || first.ResourceType != "group" || first.Name != "klb-examples-vm" {
+		t.Fatalf("expected vm example to create resgroup first, got: %+v", first)
+	}
+	for _, op := range p.Ops {
+		if op.Action == plan.Delete {
+			t.Fatalf("vm example should not delete anything, got: %+v", op)
+		}
+		if op.Action == plan.Create && op.ResourceType == "vm" && op.Name == "vm" {
+			return
+		}
+	}
+	t.Fatalf("expected vm example to create vm, plan:\n%s", p)
+}
@@ -1,16 +1,15 @@
 package azure_test
 
 import (
-	"fmt"
-	"math/rand"
 	"testing"
 
 	"github.com/NeowayLabs/klb/tests/lib/azure"
 	"github.com/NeowayLabs/klb/tests/lib/azure/fixture"
+	"github.com/NeowayLabs/klb/tests/lib/nash"
 )
 
 func genRouteTableName() string {
-	return fmt.Sprintf("klb-route-tests-%d", rand.Intn(1000))
+	return fixture.NewUniqueName("route")
 }
 
 func testRouteTableCreate(t *testing.T, f fixture.F) {
@@ -78,6 +77,7 @@ func testRouteTableAddVirtualApplianceRoute(t *testing.T, f fixture.F) {
 	hoptype := "VirtualAppliance"
 	hopaddress := "10.116.1.100"
 
+	f.Shell.EnableTranscript(f.Name)
 	f.Shell.Run(
 		"./testdata/create_route_table.sh",
 		routeTable,
@@ -99,11 +99,32 @@ func testRouteTableAddVirtualApplianceRoute(t *testing.T, f fixture.F) {
 
 	routes := azure.NewRoute(f)
 	routes.AssertVirtualApplianceRouteExists(t, routeTable, route, address, hoptype, hopaddress)
+
+	calls := f.Shell.Transcript().Calls("az", "network", "route-table", "route", "create")
+	if len(calls) == 0 {
+		t.Fatal("expected route to be created with az network route-table route create")
+	}
+	// WHY: failed attempts are tried again, the last call created the route
+	created := calls[len(calls)-1]
+	assertFlag(t, created, "--route-table-name", routeTable)
+	assertFlag(t, created, "--next-hop-type", hoptype)
+	assertFlag(t, created, "--next-hop-ip-address", hopaddress)
+}
+
+func assertFlag(t *testing.T, invocation nash.Invocation, flag string, want string) {
+	t.Helper()
+	got, ok := invocation.Flag(flag)
+	if !ok {
+		t.Fatalf("flag %s not found on %q", flag, invocation.Args)
+	}
+	if got != want {
+		t.Fatalf("expected %s[%s], got[%s] on %q", flag, want, got, invocation.Args)
+	}
 }
 
 func TestRouteTable(t *testing.T) {
 	t.Parallel()
-	fixture.Run(t, "RouteTable_Create", timeout, location, testRouteTableCreate)
-	fixture.Run(t, "RouteTable_AddInternetRoute", timeout, location, testRouteTableAddInternetRoute)
-	fixture.Run(t, "RouteTable_AddVirtualApplianceRoute", timeout, location, testRouteTableAddVirtualApplianceRoute)
+	fixture.RunCassette(t, "RouteTable_Create", timeout, location, testRouteTableCreate)
+	fixture.RunCassette(t, "RouteTable_AddInternetRoute", timeout, location, testRouteTableAddInternetRoute)
+	fixture.RunCassette(t, "RouteTable_AddVirtualApplianceRoute", timeout, location, testRouteTableAddVirtualApplianceRoute)
 }
@@ -191,19 +191,39 @@ func downloadFileBLOB(
 	container string,
 	remotepath string,
 ) string {
-	// FIXME: execWithIPC trims the contents, not a problem for now
-	return execWithIPC(t, f, func(localpath string) {
-		f.Shell.Run(
-			"./testdata/storage_download_blob.sh",
-			f.ResGroupName,
-			account,
+	return downloadContents(t, func(localpath string) {
+		f.Shell.CallE(
+			"azure/storage",
+			"azure_storage_blob_download_by_resgroup",
+			0,
 			container,
+			account,
+			f.ResGroupName,
 			remotepath,
 			localpath,
 		)
 	})
 }
 
+// downloadContents returns the contents of the
+// file downloaded to localpath by download.
+func downloadContents(t *testing.T, download func(localpath string)) string {
+	localfile, err := ioutil.TempFile("", "klb-tests-download")
+	if err != nil {
+		t.Fatalf("error creating download file: %s", err)
+	}
+	localfile.Close()
+	defer os.Remove(localfile.Name())
+
+	download(localfile.Name())
+
+	contents, err := ioutil.ReadFile(localfile.Name())
+	if err != nil {
+		t.Fatalf("error reading downloaded file: %s", err)
+	}
+	return string(contents)
+}
+
 func setupTestFile(t *testing.T, expectedContents string) (string, func()) {
 	f, err := ioutil.TempFile("", "az-storage-account-tests")
 	assert.NoError(t, err, "creating tmp file")
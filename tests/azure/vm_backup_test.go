@@ -1,10 +1,10 @@
 package azure_test
 
 import (
-	"strings"
 	"testing"
 	"time"
 
+	"github.com/NeowayLabs/klb/tests/lib/assert"
 	"github.com/NeowayLabs/klb/tests/lib/azure"
 	"github.com/NeowayLabs/klb/tests/lib/azure/fixture"
 )
@@ -158,7 +158,7 @@ func testVMBackupDataDisks(
 	defer deleteBackup(t, f, vmBackup)
 
 	backups := listBackups(t, f, vm, backupNamespace)
-	assertEqualStringSlice(t, []string{vmBackup}, backups)
+	assert.EqualStringSlicesUnordered(t, []string{vmBackup}, backups, "listing backups")
 
 	recoveredVMName := vm + "2"
 	recoverVM(
@@ -180,29 +180,10 @@ func testVMBackupDataDisks(
 	defer deleteBackup(t, f, recoveredVMBackup)
 
 	recoveredVMBackups := listBackups(t, f, recoveredVMName, backupNamespace)
-	assertEqualStringSlice(t, []string{recoveredVMBackup}, recoveredVMBackups)
+	assert.EqualStringSlicesUnordered(t, []string{recoveredVMBackup}, recoveredVMBackups, "listing recovered VM backups")
 
 	allbackups := listAllBackups(t, f, backupNamespace)
-	assertEqualStringSlice(t, []string{vmBackup, recoveredVMBackup}, allbackups)
-}
-
-func assertEqualStringSlice(t *testing.T, slice1 []string, slice2 []string) {
-	if len(slice2) != len(slice2) {
-		t.Fatalf("%+v != %+v", slice1, slice2)
-	}
-
-	for _, str1 := range slice1 {
-		found := false
-		for _, str2 := range slice2 {
-			if str1 == str2 {
-				found = true
-				break
-			}
-		}
-		if !found {
-			t.Fatalf("%+v != %+v", slice1, slice2)
-		}
-	}
+	assert.EqualStringSlicesUnordered(t, []string{vmBackup, recoveredVMBackup}, allbackups, "listing all backups")
 }
 
 func assertResourceGroupExists(t *testing.T, f fixture.F, resgroup string) {
@@ -271,50 +252,24 @@ func backupVM(
 	namespace string,
 	sku string,
 ) string {
-
-	res := execWithIPC(t, f, func(output string) {
-		f.Shell.Run(
-			"./testdata/backup_vm.sh",
-			vmname,
-			f.ResGroupName,
-			namespace,
-			sku,
-			output,
-		)
-	})
-	res = strings.TrimSpace(res)
-	return strings.Trim(res, "\n")
-}
-
-func parseBackupsList(rawlist string) []string {
-	return strings.Split(strings.Trim(strings.TrimSpace(rawlist), "\n"), "\n")
+	res := f.Shell.CallE(
+		"azure/vm",
+		"azure_vm_backup_create",
+		1,
+		vmname,
+		f.ResGroupName,
+		namespace,
+		sku,
+	)
+	return res[0][0]
 }
 
 func listAllBackups(t *testing.T, f fixture.F, prefix string) []string {
-
-	res := execWithIPC(t, f, func(outputfile string) {
-		f.Shell.Run(
-			"./testdata/list_all_backups.sh",
-			prefix,
-			outputfile,
-		)
-	})
-
-	return parseBackupsList(res)
+	return f.Shell.CallStrings("azure/vm", "azure_vm_backup_list_all", prefix)
 }
 
 func listBackups(t *testing.T, f fixture.F, vmname string, prefix string) []string {
-
-	res := execWithIPC(t, f, func(outputfile string) {
-		f.Shell.Run(
-			"./testdata/list_backups.sh",
-			vmname,
-			prefix,
-			outputfile,
-		)
-	})
-
-	return parseBackupsList(res)
+	return f.Shell.CallStrings("azure/vm", "azure_vm_backup_list", vmname, prefix)
 }
 
 func deleteBackup(t *testing.T, f fixture.F, backup string) {
@@ -2,7 +2,6 @@ package azure_test
 
 import (
 	"strconv"
-	"strings"
 	"testing"
 	"time"
 
@@ -22,9 +21,17 @@ func TestVM(t *testing.T) {
 	t.Parallel()
 	vmtesttimeout := 60 * time.Minute
 	fixture.Run(t, "VMStandardDisk", vmtesttimeout, location, testStandardDiskVM)
-	fixture.Run(t, "VMPremiumDisk", vmtesttimeout, location, testPremiumDiskVM)
-	fixture.Run(t, "VMPremiumDiskReadCache", vmtesttimeout, location, testVMPremiumDiskReadCache)
-	fixture.Run(t, "VMPremiumDiskRWCache", vmtesttimeout, location, testVMPremiumDiskRWCache)
+	fixture.RunMatrix(
+		t,
+		"VMPremiumDisk",
+		fixture.Matrix{
+			Locations: locations,
+			VMSizes:   []string{"Standard_DS4_v2"},
+			Caching:   []string{"None", "ReadOnly", "ReadWrite"},
+		},
+		vmtesttimeout,
+		testPremiumDiskVM,
+	)
 	fixture.Run(t, "VMSnapshotStandard", vmtesttimeout, location, testVMSnapshotStandard)
 	fixture.Run(t, "VMSnapshotPremium", vmtesttimeout, location, testVMSnapshotPremium)
 	fixture.Run(t, "VMPremiumDiskToStdSnapshot", vmtesttimeout, location, testVMPremiumDiskToStdSnapshot)
@@ -124,15 +131,7 @@ func testVMCreation(
 }
 
 func testPremiumDiskVM(t *testing.T, f fixture.F) {
-	testVMCreation(t, f, "Standard_DS4_v2", "Premium_LRS", "None")
-}
-
-func testVMPremiumDiskReadCache(t *testing.T, f fixture.F) {
-	testVMCreation(t, f, "Standard_DS4_v2", "Premium_LRS", "ReadOnly")
-}
-
-func testVMPremiumDiskRWCache(t *testing.T, f fixture.F) {
-	testVMCreation(t, f, "Standard_DS4_v2", "Premium_LRS", "ReadWrite")
+	testVMCreation(t, f, f.Combination.VMSize, "Premium_LRS", f.Combination.Caching)
 }
 
 func testStandardDiskVM(t *testing.T, f fixture.F) {
@@ -196,15 +195,12 @@ func testGetVMIPAddress(
 }
 
 func getVMIPs(t *testing.T, f fixture.F, vmname string) []string {
-	rawIPs := execWithIPC(t, f, func(outfile string) {
-		f.Shell.Run(
-			"./testdata/get_vm_ips.sh",
-			f.ResGroupName,
-			vmname,
-			outfile,
-		)
-	})
-	return strings.Split(rawIPs, " ")
+	return f.Shell.CallStrings(
+		"azure/vm",
+		"azure_vm_get_private_ip_addrs",
+		vmname,
+		f.ResGroupName,
+	)
 }
 
 type VMDisk struct {
@@ -250,19 +246,21 @@ func testVMSnapshot(
 
 	attachDisks(t, f, vm, disks)
 
-	idsraw := execWithIPC(t, f, func(outfile string) {
-		f.Shell.Run(
-			"./testdata/create_vm_snapshots.sh",
+	ids := []string{}
+	diskids := f.Shell.CallStrings("azure/vm", "azure_vm_get_datadisks_ids", vm, f.ResGroupName)
+	for _, diskid := range diskids {
+		res := f.Shell.CallE(
+			"azure/snapshot",
+			"azure_snapshot_create",
+			1,
+			fixture.NewUniqueName("snapshot"),
 			f.ResGroupName,
-			vm,
+			diskid,
 			snapshotSKU,
-			outfile,
 		)
-	})
-
-	f.Logger.Println("created snapshots, retrieving ids")
-	ids := strings.Split(idsraw, "\n")
-	f.Logger.Printf("parsed ids: %s", ids)
+		ids = append(ids, res[0][0])
+	}
+	f.Logger.Printf("created snapshots: %s", ids)
 
 	if len(ids) != len(disks) {
 		t.Fatalf("expected %d snapshots, got %d", len(disks), len(ids))
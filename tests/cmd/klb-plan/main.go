@@ -0,0 +1,75 @@
+// Command klb-plan prints the create/update/delete operations a
+// klb script would perform, without running any mutating az/azure
+// command, so script changes can be reviewed before running them
+// with real credentials.
+//
+// Usage:
+//
+//	klb-plan [-state state.json] [-timeout 10m] script.sh [args...]
+//
+// Read-only commands are answered from the state file, reads it
+// has no answer for are answered as not found.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/plan"
+)
+
+func main() {
+	statepath := flag.String("state", "", "JSON file with the responses of read-only commands")
+	timeout := flag.Duration("timeout", 10*time.Minute, "timeout to run the script")
+	verbose := flag.Bool("v", false, "log the script output")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] script.sh [args...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	state, err := plan.LoadState(*statepath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger := log.New(ioutil.Discard, "", 0)
+	if *verbose {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	p, err := plan.Run(ctx, logger, environ(), state, flag.Arg(0), flag.Args()[1:]...)
+	fmt.Print(p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nscript failed, the plan is incomplete:\n%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// environ returns the environment without the
+// variables set for each script run.
+func environ() []string {
+	env := []string{}
+	for _, envvar := range os.Environ() {
+		name := strings.SplitN(envvar, "=", 2)[0]
+		if name == "PATH" || name == "HOME" || name == "NASHPATH" {
+			continue
+		}
+		env = append(env, envvar)
+	}
+	return env
+}
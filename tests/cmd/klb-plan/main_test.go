@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnviron(t *testing.T) {
+	vars := map[string]string{
+		"PATH":              "/bin",
+		"HOME":              "/home/klb",
+		"NASHPATH":          "/nashpath",
+		"KLB_PLAN_TEST_VAR": "value",
+	}
+	for name, value := range vars {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+
+	names := map[string]bool{}
+	for _, envvar := range environ() {
+		names[strings.SplitN(envvar, "=", 2)[0]] = true
+	}
+	for _, name := range []string{"PATH", "HOME", "NASHPATH"} {
+		if names[name] {
+			t.Errorf("expected %s to be removed from the environment", name)
+		}
+	}
+	if !names["KLB_PLAN_TEST_VAR"] {
+		t.Error("expected KLB_PLAN_TEST_VAR to be kept on the environment")
+	}
+}
@@ -0,0 +1,56 @@
+// Command klb-progress shows a live table of the running fixture
+// tests, following their logs: what each test is doing, for how
+// long, how many times the current operation was retried and
+// the last error.
+//
+// Usage:
+//
+//	klb-progress [-dir ./tests/azure/testdata/logs/latest] [-interval 2s] [-once]
+//
+// It only understands logs saved with the JSON format,
+// the default when logging to files. It can be started before
+// the tests, waiting until they start logging.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/progress"
+)
+
+// clearScreen moves the cursor to the top and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+func main() {
+	dir := flag.String("dir", "./tests/azure/testdata/logs/latest", "dir with the logs of the tests, by default the last run")
+	interval := flag.Duration("interval", 2*time.Second, "interval between updates")
+	once := flag.Bool("once", false, "print the progress once and exit")
+	flag.Parse()
+
+	tracker := progress.NewTracker(*dir)
+	for {
+		err := tracker.Update()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !*once {
+			fmt.Print(clearScreen)
+		}
+		if tracker.Waiting() {
+			fmt.Printf("%s: waiting for the tests to log on %s\n", time.Now().Format("15:04:05"), *dir)
+		} else {
+			err = progress.Render(os.Stdout, tracker.Tests(), time.Now())
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *once {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -0,0 +1,58 @@
+package assert
+
+import (
+	"sort"
+)
+
+// EqualStringSlices fails the test if the slices don't have
+// the same strings in the same order.
+func EqualStringSlices(t T, want []string, got []string, details ...interface{}) {
+	t.Helper()
+	if diff := Diff(copyStrings(want), copyStrings(got)); diff != "" {
+		t.Fatalf("slices differ %s\n%s", errordetails(details...), diff)
+	}
+}
+
+// EqualStringSlicesUnordered fails the test if the slices don't have
+// the same strings, ignoring the order, like the ones listed from Azure.
+func EqualStringSlicesUnordered(t T, want []string, got []string, details ...interface{}) {
+	t.Helper()
+	if diff := UnorderedDiff(want, got); diff != "" {
+		t.Fatalf("slices differ, ignoring order, %s\n%s", errordetails(details...), diff)
+	}
+}
+
+// EqualStringMaps fails the test if the maps don't have the same
+// keys with the same values, like the tags of a resource.
+func EqualStringMaps(t T, want map[string]string, got map[string]string, details ...interface{}) {
+	t.Helper()
+	if diff := Diff(copyMap(want), copyMap(got)); diff != "" {
+		t.Fatalf("maps differ %s\n%s", errordetails(details...), diff)
+	}
+}
+
+// UnorderedDiff returns an unified diff between the sorted
+// slices, or an empty string if they have the same strings.
+func UnorderedDiff(want []string, got []string) string {
+	return Diff(sorted(want), sorted(got))
+}
+
+func sorted(s []string) []string {
+	res := copyStrings(s)
+	sort.Strings(res)
+	return res
+}
+
+// WHY: copies are never nil, so nil and empty
+// collections are considered equal.
+func copyStrings(s []string) []string {
+	return append([]string{}, s...)
+}
+
+func copyMap(m map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
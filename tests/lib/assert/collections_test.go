@@ -0,0 +1,146 @@
+package assert
+
+import "testing"
+
+func TestEqualStringSlices(t *testing.T) {
+	type TestCase struct {
+		name    string
+		want    []string
+		got     []string
+		failure []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "Equal",
+			want: []string{"a", "b"},
+			got:  []string{"a", "b"},
+		},
+		{
+			name: "NilAndEmpty",
+			want: nil,
+			got:  []string{},
+		},
+		{
+			name:    "Order",
+			want:    []string{"a", "b"},
+			got:     []string{"b", "a"},
+			failure: []string{"slices differ checking order", `-  (string) (len=1) "a",`},
+		},
+		{
+			name:    "Missing",
+			want:    []string{"a", "b"},
+			got:     []string{"a"},
+			failure: []string{"slices differ", `-  (string) (len=1) "b"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := record(func(rt T) {
+				EqualStringSlices(rt, test.want, test.got, "checking order")
+			})
+			assertRecorded(t, r, test.failure...)
+		})
+	}
+}
+
+func TestEqualStringSlicesUnordered(t *testing.T) {
+	type TestCase struct {
+		name    string
+		want    []string
+		got     []string
+		failure []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "SameOrder",
+			want: []string{"a", "b"},
+			got:  []string{"a", "b"},
+		},
+		{
+			name: "OtherOrder",
+			want: []string{"b", "a", "c"},
+			got:  []string{"c", "a", "b"},
+		},
+		{
+			name: "NilAndEmpty",
+			got:  []string{},
+		},
+		{
+			name:    "Duplicated",
+			want:    []string{"a", "b"},
+			got:     []string{"b", "a", "a"},
+			failure: []string{"slices differ, ignoring order, checking ids", `+  (string) (len=1) "a",`},
+		},
+		{
+			name:    "Different",
+			want:    []string{"a"},
+			got:     []string{"b"},
+			failure: []string{`-  (string) (len=1) "a"`, `+  (string) (len=1) "b"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := record(func(rt T) {
+				EqualStringSlicesUnordered(rt, test.want, test.got, "checking %s", "ids")
+			})
+			assertRecorded(t, r, test.failure...)
+		})
+	}
+}
+
+func TestEqualStringMaps(t *testing.T) {
+	type TestCase struct {
+		name    string
+		want    map[string]string
+		got     map[string]string
+		failure []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "Equal",
+			want: map[string]string{"a": "1", "b": "2"},
+			got:  map[string]string{"b": "2", "a": "1"},
+		},
+		{
+			name: "NilAndEmpty",
+			got:  map[string]string{},
+		},
+		{
+			name:    "Value",
+			want:    map[string]string{"a": "1"},
+			got:     map[string]string{"a": "2"},
+			failure: []string{"maps differ checking tags", `-  (string) (len=1) "a": (string) (len=1) "1"`},
+		},
+		{
+			name:    "Key",
+			want:    map[string]string{"a": "1"},
+			got:     map[string]string{"a": "1", "b": "2"},
+			failure: []string{`+  (string) (len=1) "b": (string) (len=1) "2"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := record(func(rt T) {
+				EqualStringMaps(rt, test.want, test.got, "checking tags")
+			})
+			assertRecorded(t, r, test.failure...)
+		})
+	}
+}
+
+func TestUnorderedDiffKeepsSlices(t *testing.T) {
+	want := []string{"b", "a"}
+	got := []string{"a", "b"}
+	if diff := UnorderedDiff(want, got); diff != "" {
+		t.Fatalf("expected no diff, got:\n%s", diff)
+	}
+	if want[0] != "b" || got[0] != "a" {
+		t.Fatalf("slices were sorted in place: want[%q] got[%q]", want, got)
+	}
+}
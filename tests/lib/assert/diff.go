@@ -0,0 +1,49 @@
+package assert
+
+import (
+	"reflect"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// dumper prints values the same way every time, so
+// only their actual differences show up on diffs.
+var dumper = spew.ConfigState{
+	Indent:                  "  ",
+	DisablePointerAddresses: true,
+	DisableCapacities:       true,
+	DisableMethods:          true,
+	SortKeys:                true,
+}
+
+// Equal fails the test if want and got are not deeply equal,
+// showing an unified diff between them, useful for structs.
+func Equal(t T, want interface{}, got interface{}, details ...interface{}) {
+	t.Helper()
+	if diff := Diff(want, got); diff != "" {
+		t.Fatalf("values differ %s\n%s", errordetails(details...), diff)
+	}
+}
+
+// Diff returns an unified diff between want and got, or an empty
+// string if they are deeply equal. It is useful to compare values
+// inside retrier work functions, that can't fail the test.
+func Diff(want interface{}, got interface{}) string {
+	if reflect.DeepEqual(want, got) {
+		return ""
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(dumper.Sdump(want)),
+		B:        difflib.SplitLines(dumper.Sdump(got)),
+		FromFile: "want",
+		ToFile:   "got",
+		Context:  3,
+	})
+	if err != nil || diff == "" {
+		// WHY: values that are not deeply equal can still
+		// have the same dump, like different func values.
+		return dumper.Sprintf("want: %#v\ngot: %#v\n", want, got)
+	}
+	return diff
+}
@@ -0,0 +1,115 @@
+package assert
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// recorder is a fake testing.T recording the failure of an
+// assert, stopping it like testing.T does.
+type recorder struct {
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// record runs the assert with a recorder, on its own
+// goroutine since failures stop it.
+func record(assert func(t T)) *recorder {
+	r := &recorder{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert(r)
+	}()
+	<-done
+	return r
+}
+
+// assertRecorded checks the assert failed with a message containing
+// all the wanted strings, or passed when none is wanted.
+func assertRecorded(t *testing.T, r *recorder, want ...string) {
+	t.Helper()
+	if len(want) == 0 {
+		if r.failed {
+			t.Fatalf("expected assert to pass, failed with:\n%s", r.msg)
+		}
+		return
+	}
+	if !r.failed {
+		t.Fatalf("expected assert to fail with[%q]", want)
+	}
+	for _, w := range want {
+		if !strings.Contains(r.msg, w) {
+			t.Errorf("expected[%s] on failure:\n%s", w, r.msg)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	type resource struct {
+		Name string
+		Tags map[string]string
+	}
+
+	type TestCase struct {
+		name    string
+		want    interface{}
+		got     interface{}
+		details []interface{}
+		failure []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "Equal",
+			want: resource{Name: "vm", Tags: map[string]string{"a": "1"}},
+			got:  resource{Name: "vm", Tags: map[string]string{"a": "1"}},
+		},
+		{
+			name:    "Differ",
+			want:    resource{Name: "vm", Tags: map[string]string{"a": "1"}},
+			got:     resource{Name: "vm", Tags: map[string]string{"a": "2"}},
+			details: []interface{}{"checking %s", "tags"},
+			failure: []string{
+				"values differ checking tags",
+				"--- want\n+++ got\n",
+				`-    (string) (len=1) "a": (string) (len=1) "1"`,
+				`+    (string) (len=1) "a": (string) (len=1) "2"`,
+			},
+		},
+		{
+			name:    "DifferentTypes",
+			want:    1,
+			got:     "1",
+			failure: []string{"-(int) 1", `+(string) (len=1) "1"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := record(func(rt T) {
+				Equal(rt, test.want, test.got, test.details...)
+			})
+			assertRecorded(t, r, test.failure...)
+		})
+	}
+}
+
+func TestDiffSameDump(t *testing.T) {
+	// WHY: func values are never deeply equal, but have the same dump
+	f := func() {}
+	diff := Diff(f, f)
+	if !strings.HasPrefix(diff, "want: ") || !strings.Contains(diff, "\ngot: ") {
+		t.Fatalf("expected want and got on the diff, got:\n%s", diff)
+	}
+}
@@ -1,20 +1,24 @@
 package assert
 
-import (
-	"fmt"
-	"testing"
-)
+import "fmt"
 
-func EqualStrings(t *testing.T, want string, got string, details ...interface{}) {
-	// TODO: could use t.Helper here, but only on Go 1.9
+// T is the part of testing.T used by the asserts, so
+// their failures can be tested.
+type T interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+}
+
+func EqualStrings(t T, want string, got string, details ...interface{}) {
+	t.Helper()
 	if want != got {
 		detail := errordetails(details...)
 		t.Fatalf("wanted[%s] but got[%s] %s", want, got, detail)
 	}
 }
 
-func EqualInts(t *testing.T, want int, got int, details ...interface{}) {
-	// TODO: could use t.Helper here, but only on Go 1.9
+func EqualInts(t T, want int, got int, details ...interface{}) {
+	t.Helper()
 	if want != got {
 		detail := errordetails(details...)
 		t.Fatalf("wanted[%d] but got[%d] %s", want, got, detail)
@@ -1,8 +1,7 @@
 package assert
 
-import "testing"
-
-func NoError(t *testing.T, err error, details ...interface{}) {
+func NoError(t T, err error, details ...interface{}) {
+	t.Helper()
 	if err != nil {
 		t.Fatalf("unexpected error[%s] %s", err, errordetails(details...))
 	}
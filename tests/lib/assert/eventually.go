@@ -0,0 +1,168 @@
+package assert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/retrier"
+)
+
+// Probe observes a value, like a field of a resource got from
+// Azure. Prefer returning plain values over SDK structs, they
+// are easier to compare and to read on failures.
+type Probe func(ctx context.Context) (interface{}, error)
+
+// Expectation checks an observed value, returning
+// why it is not the expected one.
+type Expectation func(value interface{}) error
+
+// Is expects values deeply equal to want.
+func Is(want interface{}) Expectation {
+	return func(got interface{}) error {
+		if diff := Diff(want, got); diff != "" {
+			return fmt.Errorf("unexpected value:\n%s", diff)
+		}
+		return nil
+	}
+}
+
+// IsUnordered expects string slices with the same strings as
+// want, ignoring the order, like IDs listed from Azure.
+func IsUnordered(want []string) Expectation {
+	return func(value interface{}) error {
+		got, ok := value.([]string)
+		if !ok {
+			return fmt.Errorf("expected a []string, got %T", value)
+		}
+		if diff := UnorderedDiff(want, got); diff != "" {
+			return fmt.Errorf("unexpected values, ignoring order:\n%s", diff)
+		}
+		return nil
+	}
+}
+
+// Eventually probes until the observed value meets the expectation,
+// using the given retrier, like the fixture one. Probe errors, like
+// Azure API errors, are tried again too, until ctx is done. If the
+// expectation is never met the test fails with every observed value.
+func Eventually(
+	ctx context.Context,
+	t *testing.T,
+	r *retrier.Retrier,
+	name string,
+	probe Probe,
+	expect Expectation,
+) {
+	t.Helper()
+	h := &history{}
+	err := r.RunE(ctx, name, func(ctx context.Context) error {
+		value, err := probe(ctx)
+		h.observe(value, err)
+		if err != nil {
+			// WHY: resources may not be readable right away
+			return retrier.Retry(err)
+		}
+		return expect(value)
+	})
+	if err != nil {
+		t.Fatalf("%q never met the expectation: %s\n%s", name, err, h)
+	}
+}
+
+// Consistently probes during the given duration, failing the test with
+// every observed value as soon as one does not meet the expectation.
+// Errors getting a value, like Azure API errors, are not violations,
+// the probe is tried again with the given retrier until ctx is done.
+func Consistently(
+	ctx context.Context,
+	t *testing.T,
+	r *retrier.Retrier,
+	name string,
+	duration time.Duration,
+	probe Probe,
+	expect Expectation,
+) {
+	t.Helper()
+	h := &history{}
+	deadline := time.Now().Add(duration)
+	interval := pollInterval(duration)
+	for {
+		var value interface{}
+		err := r.RunE(ctx, name, func(ctx context.Context) error {
+			var err error
+			value, err = probe(ctx)
+			h.observe(value, err)
+			if err != nil {
+				return retrier.Retry(err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%q could not be probed: %s\n%s", name, err, h)
+		}
+		if err := expect(value); err != nil {
+			t.Fatalf("%q did not stay as expected: %s\n%s", name, err, h)
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			t.Fatalf("%q: test timeouted before %s passed\n%s", name, duration, h)
+		}
+	}
+}
+
+// pollInterval probes about ten times during the duration,
+// but not more than once per second.
+func pollInterval(duration time.Duration) time.Duration {
+	interval := duration / 10
+	if interval < time.Second {
+		return time.Second
+	}
+	return interval
+}
+
+type observation struct {
+	time  time.Time
+	value interface{}
+	err   error
+}
+
+// history has every value observed by a probe.
+type history struct {
+	sync.Mutex
+	observations []observation
+}
+
+func (h *history) observe(value interface{}, err error) {
+	h.Lock()
+	defer h.Unlock()
+	h.observations = append(h.observations, observation{
+		time:  time.Now(),
+		value: value,
+		err:   err,
+	})
+}
+
+func (h *history) String() string {
+	h.Lock()
+	defer h.Unlock()
+	lines := []string{"observed values in order:"}
+	for _, o := range h.observations {
+		observed := dumper.Sprintf("%+v", o.value)
+		if o.err != nil {
+			observed = fmt.Sprintf("error: %s", o.err)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", o.time.Format("15:04:05.000"), observed))
+	}
+	return strings.Join(lines, "\n")
+}
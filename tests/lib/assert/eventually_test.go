@@ -0,0 +1,84 @@
+package assert
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/retrier"
+)
+
+func TestEventuallyRetriesProbeErrors(t *testing.T) {
+	type TestCase struct {
+		name string
+		errs []error
+	}
+
+	tests := []TestCase{
+		{
+			name: "NotFound",
+			errs: []error{errors.New("StatusCode=404"), errors.New("StatusCode=404")},
+		},
+		{
+			name: "Permanent",
+			errs: []error{errors.New("StatusCode=400"), errors.New("StatusCode=403")},
+		},
+		{
+			name: "Unknown",
+			errs: []error{errors.New("boom")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			probes := 0
+			Eventually(ctx, t, newRetrier(ctx), "probe", func(context.Context) (interface{}, error) {
+				probes++
+				if probes <= len(test.errs) {
+					return nil, test.errs[probes-1]
+				}
+				// WHY: the first value does not meet the expectation
+				return probes, nil
+			}, Is(len(test.errs)+2))
+
+			if probes != len(test.errs)+2 {
+				t.Errorf("expected[%d] probes, got[%d]", len(test.errs)+2, probes)
+			}
+		})
+	}
+}
+
+func TestConsistentlyRetriesProbeErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	probes := 0
+	Consistently(ctx, t, newRetrier(ctx), "probe", time.Second, func(context.Context) (interface{}, error) {
+		probes++
+		if probes == 1 {
+			return nil, errors.New("StatusCode=400")
+		}
+		return "value", nil
+	}, Is("value"))
+
+	if probes != 2 {
+		t.Errorf("expected[2] probes, got[%d]", probes)
+	}
+}
+
+func newRetrier(ctx context.Context) *retrier.Retrier {
+	logger := testlog.Wrap(log.New(ioutil.Discard, "", 0))
+	r := retrier.New(ctx, nil, logger, retrier.Policy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})
+	r.SetClassifier(retrier.Polling)
+	return r
+}
@@ -0,0 +1,26 @@
+package assert
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Contains fails the test if s does not contain substr.
+func Contains(t T, s string, substr string, details ...interface{}) {
+	t.Helper()
+	if !strings.Contains(s, substr) {
+		t.Fatalf("wanted[%s] to contain[%s] %s", s, substr, errordetails(details...))
+	}
+}
+
+// Matches fails the test if s does not match the given regular expression.
+func Matches(t T, s string, pattern string, details ...interface{}) {
+	t.Helper()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Fatalf("invalid pattern[%s]: %s", pattern, err)
+	}
+	if !re.MatchString(s) {
+		t.Fatalf("wanted[%s] to match[%s] %s", s, pattern, errordetails(details...))
+	}
+}
@@ -0,0 +1,78 @@
+package assert
+
+import "testing"
+
+func TestContains(t *testing.T) {
+	type TestCase struct {
+		name    string
+		s       string
+		substr  string
+		failure []string
+	}
+
+	tests := []TestCase{
+		{
+			name:   "Contains",
+			s:      "/subscriptions/sub/resourceGroups/rg",
+			substr: "resourceGroups/rg",
+		},
+		{
+			name:   "Empty",
+			s:      "anything",
+			substr: "",
+		},
+		{
+			name:    "Missing",
+			s:       "/subscriptions/sub/resourceGroups/rg",
+			substr:  "virtualMachines",
+			failure: []string{"wanted[/subscriptions/sub/resourceGroups/rg] to contain[virtualMachines] checking id"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := record(func(rt T) {
+				Contains(rt, test.s, test.substr, "checking id")
+			})
+			assertRecorded(t, r, test.failure...)
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	type TestCase struct {
+		name    string
+		s       string
+		pattern string
+		failure []string
+	}
+
+	tests := []TestCase{
+		{
+			name:    "Matches",
+			s:       "klb-TestVM-1508439367-7943",
+			pattern: `^klb-TestVM-[0-9]+-[0-9]+$`,
+		},
+		{
+			name:    "DoesNotMatch",
+			s:       "klb-TestNIC-1508439367-7943",
+			pattern: `^klb-TestVM-`,
+			failure: []string{"wanted[klb-TestNIC-1508439367-7943] to match[^klb-TestVM-] checking name"},
+		},
+		{
+			name:    "InvalidPattern",
+			s:       "klb",
+			pattern: `klb-(`,
+			failure: []string{"invalid pattern[klb-(]"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := record(func(rt T) {
+				Matches(rt, test.s, test.pattern, "checking name")
+			})
+			assertRecorded(t, r, test.failure...)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -37,9 +38,17 @@ func NewLoadBalancers(f fixture.F) *LoadBalancers {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (lb *LoadBalancers) clientFor(ctx context.Context) network.LoadBalancersClient {
+	client := lb.client
+	client.Sender = lb.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if load balancer exists in the resource group.
 // Fail tests otherwise.
 func (lb *LoadBalancers) AssertExists(
@@ -49,8 +58,8 @@ func (lb *LoadBalancers) AssertExists(
 	privateIP string,
 	poolname string,
 ) {
-	lb.f.Retrier.Run(newID("LoadBalancers", "AssertExists", name), func() error {
-		loadbalancer, err := lb.getLoadBalancer(t, name)
+	lb.f.Retrier.RunContext(newID("LoadBalancers", "AssertExists", name), func(ctx context.Context) error {
+		loadbalancer, err := lb.getLoadBalancer(ctx, t, name)
 		if err != nil {
 			return err
 		}
@@ -58,11 +67,23 @@ func (lb *LoadBalancers) AssertExists(
 	})
 }
 
+// Get returns the load balancer with the given name.
+// Fail tests if it can't be found.
+func (lb *LoadBalancers) Get(t *testing.T, name string) network.LoadBalancer {
+	var loadbalancer network.LoadBalancer
+	lb.f.Retrier.RunContext(newID("LoadBalancers", "Get", name), func(ctx context.Context) error {
+		var err error
+		loadbalancer, err = lb.getLoadBalancer(ctx, t, name)
+		return err
+	})
+	return loadbalancer
+}
+
 // AssertRuleExists checks if load balancer exists and it has the given rule.
 // Fail tests otherwise.
 func (lb *LoadBalancers) AssertRuleExists(t *testing.T, lbname string, r LoadBalancerRule) {
-	lb.f.Retrier.Run(newID("LoadBalancers", "AssertRuleExists", r.Name), func() error {
-		loadbalancer, err := lb.getLoadBalancer(t, lbname)
+	lb.f.Retrier.RunContext(newID("LoadBalancers", "AssertRuleExists", r.Name), func(ctx context.Context) error {
+		loadbalancer, err := lb.getLoadBalancer(ctx, t, lbname)
 		if err != nil {
 			return err
 		}
@@ -122,8 +143,8 @@ func (lb *LoadBalancers) AssertRuleExists(t *testing.T, lbname string, r LoadBal
 // AssertProbeExists checks if load balancer exists and it has the given probe.
 // Fail tests otherwise.
 func (lb *LoadBalancers) AssertProbeExists(t *testing.T, lbname string, p LoadBalancerProbe) {
-	lb.f.Retrier.Run(newID("LoadBalancers", "AssertProbeExists", p.Name), func() error {
-		loadbalancer, err := lb.getLoadBalancer(t, lbname)
+	lb.f.Retrier.RunContext(newID("LoadBalancers", "AssertProbeExists", p.Name), func(ctx context.Context) error {
+		loadbalancer, err := lb.getLoadBalancer(ctx, t, lbname)
 		if err != nil {
 			return err
 		}
@@ -223,8 +244,8 @@ func checkProbePropertiesFormat(p *network.ProbePropertiesFormat) (*network.Prob
 	return p, nil
 }
 
-func (lb *LoadBalancers) getLoadBalancer(t *testing.T, name string) (network.LoadBalancer, error) {
-	res, err := lb.client.List(lb.f.ResGroupName)
+func (lb *LoadBalancers) getLoadBalancer(ctx context.Context, t *testing.T, name string) (network.LoadBalancer, error) {
+	res, err := lb.clientFor(ctx).List(lb.f.ResGroupName)
 	if err != nil {
 		return network.LoadBalancer{}, err
 	}
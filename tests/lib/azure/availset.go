@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -19,22 +20,30 @@ func NewAvailSet(f fixture.F) *AvailSet {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (av *AvailSet) clientFor(ctx context.Context) compute.AvailabilitySetsClient {
+	client := av.client
+	client.Sender = av.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if availability sets exists in the resource group.
 // Fail tests otherwise.
 func (av *AvailSet) AssertExists(t *testing.T, name string) {
-	av.f.Retrier.Run(newID("AvailSet", "AssertExists", name), func() error {
-		_, err := av.client.Get(av.f.ResGroupName, name)
+	av.f.Retrier.RunContext(newID("AvailSet", "AssertExists", name), func(ctx context.Context) error {
+		_, err := av.clientFor(ctx).Get(av.f.ResGroupName, name)
 		return err
 	})
 }
 
 // AssertDeleted checks if resource was correctly deleted.
 func (av *AvailSet) AssertDeleted(t *testing.T, name string) {
-	av.f.Retrier.Run(newID("AvailSet", "AssertDeleted", name), func() error {
-		_, err := av.client.Get(av.f.ResGroupName, name)
+	av.f.Retrier.RunContext(newID("AvailSet", "AssertDeleted", name), func(ctx context.Context) error {
+		_, err := av.clientFor(ctx).Get(av.f.ResGroupName, name)
 		if err == nil {
 			return fmt.Errorf("resource %s should not exist", name)
 		}
@@ -44,8 +53,8 @@ func (av *AvailSet) AssertDeleted(t *testing.T, name string) {
 
 // Delete the availability set
 func (av *AvailSet) Delete(t *testing.T, name string) {
-	av.f.Retrier.Run(newID("AvailSet", "Delete", name), func() error {
-		_, err := av.client.Delete(av.f.ResGroupName, name)
+	av.f.Retrier.RunContext(newID("AvailSet", "Delete", name), func(ctx context.Context) error {
+		_, err := av.clientFor(ctx).Delete(av.f.ResGroupName, name)
 		return err
 	})
 }
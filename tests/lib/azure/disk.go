@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -20,14 +21,22 @@ func NewDisk(f fixture.F) *Disks {
 		client: disk.NewDisksClient(f.Session.SubscriptionID),
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (d *Disks) clientFor(ctx context.Context) disk.DisksClient {
+	client := d.client
+	client.Sender = d.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if disk exists in the resource group.
 // Fail tests otherwise.
 func (d *Disks) AssertExists(t *testing.T, name string, size int, sku string) {
-	d.f.Retrier.Run(newID("Disk", "AssertExists", name), func() error {
-		res, err := d.client.Get(d.f.ResGroupName, name)
+	d.f.Retrier.RunContext(newID("Disk", "AssertExists", name), func(ctx context.Context) error {
+		res, err := d.clientFor(ctx).Get(d.f.ResGroupName, name)
 		if err != nil {
 			return err
 		}
@@ -3,13 +3,17 @@ package fixture
 import (
 	"context"
 	"fmt"
-	"log"
 	"math/rand"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/NeowayLabs/klb/tests/lib/cassette"
 	testlog "github.com/NeowayLabs/klb/tests/lib/log"
 	"github.com/NeowayLabs/klb/tests/lib/nash"
+	"github.com/NeowayLabs/klb/tests/lib/ratelimit"
+	"github.com/NeowayLabs/klb/tests/lib/report"
 	"github.com/NeowayLabs/klb/tests/lib/retrier"
 )
 
@@ -23,20 +27,47 @@ type F struct {
 	ResGroupName string
 	//Session used to interact with the Azure API
 	Session *Session
+	//Sender sends the Azure API requests within the subscription
+	//rate limit, all Azure clients must use it
+	Sender autorest.Sender
 	//Location where resources are created
 	Location string
 	//Name is the test name
 	Name string
 	//Logger useful to log on your tests, bypass go test default
-	Logger *log.Logger
-	//Shell nash shell wrapper, ready to execute scripts
+	Logger *testlog.Logger
+	//Shell nash shell wrapper, ready to execute scripts and call
+	//klb functions, calls log in only once on the shell worker
 	Shell *nash.Shell
-	//Retrier retrier can be used to run functions until context is cancelled
+	//Retrier retrier can be used to run functions until context is cancelled,
+	//it uses the retrier.Fast policy and the retrier.Polling classifier
+	//since it is meant for asserts
 	Retrier *retrier.Retrier
+	//Combination has the values chosen for this test by RunMatrix,
+	//it is empty for tests started with Run
+	Combination Combination
+	//Replaying is true when RunCassette replays a cassette, nothing
+	//changes on Azure, so tests don't need to wait for it to settle
+	Replaying bool
 }
 
 type Test func(*testing.T, F)
 
+// SenderFor returns the fixture Sender sending the requests bound
+// to ctx, like the context of a retrier attempt, so they are
+// cancelled when it is done. The Sender itself sends requests
+// that can't be cancelled.
+func (f F) SenderFor(ctx context.Context) autorest.Sender {
+	return bindSender(ctx, f.Sender)
+}
+
+// bindSender returns a sender that binds each request to ctx.
+func bindSender(ctx context.Context, sender autorest.Sender) autorest.Sender {
+	return autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+		return sender.Do(req.WithContext(ctx))
+	})
+}
+
 // Run creates a unique resource group based on testname and calls
 // the given testfunc passing as argument all the resources required
 // to test integration with Azure cloud.
@@ -60,45 +91,172 @@ func Run(
 	timeout time.Duration,
 	location string,
 	testfunc Test,
+) {
+	run(t, testname, timeout, location, false, testfunc)
+}
+
+// RunCassette runs the test just like Run, recording the az and azure
+// commands run by the shell and the requests sent with the sender on
+// a cassette at ./testdata/cassettes/<TestName>, see the cassette
+// package. Passing tests save the cassette.
+//
+// When the cassette exists the test replays it offline: no
+// credentials are needed, the resource group is not created and
+// the rate limit is not used. Test functions must use only the
+// fixture shell, sender and retrier to reach Azure.
+func RunCassette(
+	t *testing.T,
+	testname string,
+	timeout time.Duration,
+	location string,
+	testfunc Test,
+) {
+	run(t, testname, timeout, location, true, testfunc)
+}
+
+func run(
+	t *testing.T,
+	testname string,
+	timeout time.Duration,
+	location string,
+	usecassette bool,
+	testfunc Test,
 ) {
 	//FIXME: We could remove testname on Go 1.8
 	t.Run(testname, func(t *testing.T) {
 		t.Parallel()
+		start := time.Now()
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
 		logger, teardown := testlog.New(t, testname)
 		defer teardown()
 
-		session := NewSession(t)
+		var records *cassette.Cassette
+		if usecassette {
+			var err error
+			records, err = cassette.Open(cassette.Dir, t.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		offline := records != nil && records.Replaying()
+
+		var session *Session
+		if offline {
+			session = newOfflineSession(t)
+		} else {
+			session = NewSession(t)
+		}
 		resgroup := NewUniqueName(testname)
-		resources := NewResourceGroup(ctx, t, session, logger)
+		logger = logger.With(testlog.Fields{ResGroup: resgroup})
+		setupLogger := logger.With(testlog.Fields{Phase: testlog.PhaseSetup})
+		teardownLogger := logger.With(testlog.Fields{Phase: testlog.PhaseTeardown})
+		scriptLogger := logger.With(testlog.Fields{Phase: testlog.PhaseScript})
+
+		deleted := false
+		kept := false
+		defer func() {
+			report.Record(report.Test{
+				Name:     t.Name(),
+				Location: location,
+				ResGroup: resgroup,
+				Start:    start,
+				Duration: time.Since(start),
+				Failed:   t.Failed(),
+				LogsPath: testlog.LogsPath(testname),
+				Deleted:  deleted,
+				Kept:     kept,
+			})
+		}()
+
+		tags := resgroupTags(t.Name(), testlog.RunID())
+		resources := NewResourceGroup(ctx, t, session, setupLogger)
 		defer func() {
+			if offline {
+				// WHY: replayed tests create nothing on Azure
+				deleted = true
+				return
+			}
 			// We cant use an expired context when cleaning up state from Azure.
 			const resourceCleanupTimeout = 30 * time.Second
 			ctx, cancel := context.WithTimeout(context.Background(), resourceCleanupTimeout)
 			defer cancel()
-			resources := NewResourceGroup(ctx, t, session, logger)
-			resources.Delete(t, resgroup)
+			resources := NewResourceGroup(ctx, t, session, teardownLogger)
+			expiresAt := time.Now().Add(keepFor)
+			kept, deleted = teardownResGroup(
+				t, resources, teardownLogger, resgroup, tags, t.Failed(), expiresAt,
+			)
+			if kept {
+				// WHY: logs may be on files, the kept resource
+				// group must be easy to find on the test output.
+				msg := fmt.Sprintf(
+					"fixture: kept resgroup %q until %s: %s",
+					resgroup,
+					expiresAt.Format(time.RFC3339),
+					portalURL(session, resgroup),
+				)
+				teardownLogger.Println(msg)
+				t.Log(msg)
+			}
 		}()
 
-		logger.Printf("fixture: setting up resgroup %q at %q", resgroup, location)
-		resources.Create(t, resgroup, location)
-		resources.AssertExists(t, resgroup)
-		logger.Printf("fixture: created resgroup %q with success", resgroup)
+		if offline {
+			setupLogger.Printf("fixture: replaying %s, resgroup %q is not created", records.Path(), resgroup)
+		} else {
+			setupLogger.Printf("fixture: setting up resgroup %q at %q", resgroup, location)
+			resources.Create(t, resgroup, location, tags)
+			resources.AssertExists(t, resgroup)
+			setupLogger.Printf("fixture: created resgroup %q with success", resgroup)
+		}
+
+		shell := nash.New(ctx, t, scriptLogger, session.Env())
+		shell.EnableWorker()
+		defer shell.Close()
+
+		sender := ratelimit.Subscription.Sender(ctx, t.Name())
+		if records != nil {
+			records.SetContext(session.Env())
+			sender = records.Sender(sender)
+			shell.UseCassette(records)
+			defer func() {
+				if t.Failed() {
+					return
+				}
+				err := records.Save()
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		assertRetrier := retrier.New(
+			ctx,
+			t,
+			logger.With(testlog.Fields{Phase: testlog.PhaseAssert}),
+			retrier.Fast,
+		)
+		// WHY: asserts poll resources that may not be visible yet
+		assertRetrier.SetClassifier(retrier.Polling)
 
-		logger.Println("fixture: calling test function")
+		setupLogger.Println("fixture: calling test function")
 		testfunc(t, F{
 			Ctx:          ctx,
 			Name:         testname,
 			ResGroupName: resgroup,
 			Session:      session,
+			Sender:       sender,
 			Location:     location,
-			Logger:       logger,
-			Shell:        nash.New(ctx, t, logger, session.Env()),
-			Retrier:      retrier.New(ctx, t, logger),
+			Logger:       logger.With(testlog.Fields{Phase: testlog.PhaseTest}),
+			Shell:        shell,
+			Retrier:      assertRetrier,
+			Replaying:    offline,
 		})
-		logger.Printf("fixture: finished, failed=%t", t.Failed())
+		teardownLogger.Printf(
+			"fixture: finished, failed=%t, waited %s on the rate limit",
+			t.Failed(),
+			ratelimit.Subscription.Waited(t.Name()),
+		)
 	})
 }
 
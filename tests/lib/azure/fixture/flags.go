@@ -0,0 +1,18 @@
+package fixture
+
+import (
+	"flag"
+)
+
+// RegisterFlags registers the flags of the fixture, like the ones
+// choosing the combinations run by RunMatrix and keeping resource
+// groups. It must be called on TestMain before parsing flags.
+func RegisterFlags(flags *flag.FlagSet) {
+	flags.Var(&locations, "locations", "run matrix tests on the given comma separated locations instead of the default ones")
+	flags.Var(&filters.vmsizes, "vmsizes", "only run matrix tests with the given comma separated VM sizes")
+	flags.Var(&filters.storageskus, "storageskus", "only run matrix tests with the given comma separated storage SKUs")
+	flags.Var(&filters.accesstiers, "accesstiers", "only run matrix tests with the given comma separated access tiers")
+	flags.Var(&filters.caching, "caching", "only run matrix tests with the given comma separated caching modes")
+	flags.Var(&keep, "keep", "keep the resource groups of tests, valid values: 'never' 'failed' 'always'")
+	flags.DurationVar(&keepFor, "keepfor", keepFor, "how long kept resource groups are needed, saved on their expires-at tag")
+}
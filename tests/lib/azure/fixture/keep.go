@@ -0,0 +1,120 @@
+package fixture
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// keepMode is when resource groups are kept instead of deleted.
+type keepMode string
+
+const (
+	keepNever  keepMode = "never"
+	keepFailed keepMode = "failed"
+	keepAlways keepMode = "always"
+)
+
+var keep = keepNever
+
+var keepFor = 24 * time.Hour
+
+func (k *keepMode) String() string {
+	return string(*k)
+}
+
+func (k *keepMode) Set(value string) error {
+	switch keepMode(value) {
+	case keepNever, keepFailed, keepAlways:
+		*k = keepMode(value)
+		return nil
+	}
+	return fmt.Errorf("unknown keep mode: %s", value)
+}
+
+func keepResGroup(failed bool) bool {
+	return keep == keepAlways || (keep == keepFailed && failed)
+}
+
+// Tags of the resource groups created by the fixture, so kept
+// ones can be traced back to their tests and cleaned up.
+const (
+	TagTest      = "klb-test"
+	TagRunID     = "klb-run-id"
+	TagCreator   = "klb-creator"
+	TagExpiresAt = "expires-at"
+)
+
+func resgroupTags(testname string, runID string) map[string]string {
+	return map[string]string{
+		TagTest:    testname,
+		TagRunID:   runID,
+		TagCreator: creator(),
+	}
+}
+
+// keptTags returns the tags of a kept resource group,
+// with the time it expires at.
+func keptTags(tags map[string]string, expiresAt time.Time) map[string]string {
+	kept := map[string]string{TagExpiresAt: expiresAt.UTC().Format(time.RFC3339)}
+	for k, v := range tags {
+		kept[k] = v
+	}
+	return kept
+}
+
+// resgroupKeeper keeps or deletes resource groups,
+// like ResourceGroup does.
+type resgroupKeeper interface {
+	Keep(t *testing.T, name string, tags map[string]string, expiresAt time.Time) error
+	Delete(t *testing.T, name string) bool
+}
+
+// teardownResGroup keeps the resource group of the test until
+// expiresAt when the keep mode requires it, deleting it otherwise.
+// It tells if the resource group was kept or deleted.
+func teardownResGroup(
+	t *testing.T,
+	keeper resgroupKeeper,
+	logger *testlog.Logger,
+	resgroup string,
+	tags map[string]string,
+	failed bool,
+	expiresAt time.Time,
+) (bool, bool) {
+	if !keepResGroup(failed) {
+		return false, keeper.Delete(t, resgroup)
+	}
+	err := keeper.Keep(t, resgroup, tags, expiresAt)
+	if err != nil {
+		// WHY: an untagged resource group would never expire,
+		// so it is deleted just like when it is not kept.
+		logger.Printf("fixture: unable to keep resgroup %q, deleting it", resgroup)
+		return false, keeper.Delete(t, resgroup)
+	}
+	return true, false
+}
+
+// creator is who is running the tests, the user or
+// the host name on CI, where there is usually no user.
+func creator() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "unknown"
+}
+
+func portalURL(s *Session, resgroup string) string {
+	return fmt.Sprintf(
+		"https://portal.azure.com/#@%s/resource/subscriptions/%s/resourceGroups/%s/overview",
+		s.TenantID,
+		s.SubscriptionID,
+		resgroup,
+	)
+}
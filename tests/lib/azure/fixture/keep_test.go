@@ -0,0 +1,150 @@
+package fixture
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+func TestKeepModeSet(t *testing.T) {
+	type TestCase struct {
+		name    string
+		value   string
+		want    keepMode
+		wantErr bool
+	}
+
+	tests := []TestCase{
+		{name: "Never", value: "never", want: keepNever},
+		{name: "Failed", value: "failed", want: keepFailed},
+		{name: "Always", value: "always", want: keepAlways},
+		{name: "Empty", value: "", want: keepFailed, wantErr: true},
+		{name: "Unknown", value: "sometimes", want: keepFailed, wantErr: true},
+		{name: "CaseSensitive", value: "Always", want: keepFailed, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mode := keepFailed
+			err := mode.Set(test.value)
+			if test.wantErr != (err != nil) {
+				t.Fatalf("expected error[%t], got[%v]", test.wantErr, err)
+			}
+			if mode != test.want {
+				t.Errorf("expected mode[%s], got[%s]", test.want, mode)
+			}
+		})
+	}
+}
+
+func TestResGroupTags(t *testing.T) {
+	defer os.Setenv("USER", os.Getenv("USER"))
+	os.Setenv("USER", "klb-user")
+
+	got := resgroupTags("TestVM/VMStandardDisk", "20171020-180000")
+	want := map[string]string{
+		TagTest:    "TestVM/VMStandardDisk",
+		TagRunID:   "20171020-180000",
+		TagCreator: "klb-user",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected[%v], got[%v]", want, got)
+	}
+}
+
+func TestKeptTags(t *testing.T) {
+	tags := map[string]string{TagTest: "TestVM"}
+	saopaulo := time.FixedZone("BRT", -3*60*60)
+	expiresAt := time.Date(2017, 10, 20, 21, 30, 0, 0, saopaulo)
+
+	got := keptTags(tags, expiresAt)
+	want := map[string]string{
+		TagTest:      "TestVM",
+		TagExpiresAt: "2017-10-21T00:30:00Z",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected[%v], got[%v]", want, got)
+	}
+	if _, ok := tags[TagExpiresAt]; ok {
+		t.Fatalf("expected given tags unchanged, got[%v]", tags)
+	}
+}
+
+// fakeKeeper records the resource groups kept and deleted.
+type fakeKeeper struct {
+	keepErr error
+	kept    []string
+	deleted []string
+}
+
+func (f *fakeKeeper) Keep(t *testing.T, name string, tags map[string]string, expiresAt time.Time) error {
+	if f.keepErr != nil {
+		return f.keepErr
+	}
+	f.kept = append(f.kept, name)
+	return nil
+}
+
+func (f *fakeKeeper) Delete(t *testing.T, name string) bool {
+	f.deleted = append(f.deleted, name)
+	return true
+}
+
+func TestTeardownResGroup(t *testing.T) {
+	type TestCase struct {
+		name    string
+		mode    keepMode
+		failed  bool
+		keepErr error
+		kept    bool
+		deleted bool
+	}
+
+	tests := []TestCase{
+		{name: "Never", mode: keepNever, failed: true, deleted: true},
+		{name: "FailedPassing", mode: keepFailed, deleted: true},
+		{name: "FailedFailing", mode: keepFailed, failed: true, kept: true},
+		{name: "Always", mode: keepAlways, kept: true},
+		{name: "TagFails", mode: keepAlways, keepErr: errors.New("StatusCode=500"), deleted: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(saved keepMode) {
+				keep = saved
+			}(keep)
+			keep = test.mode
+
+			keeper := &fakeKeeper{keepErr: test.keepErr}
+			logger := testlog.Wrap(log.New(ioutil.Discard, "", 0))
+			kept, deleted := teardownResGroup(
+				t, keeper, logger, "klb-rg", map[string]string{}, test.failed, time.Now(),
+			)
+			if kept != test.kept || deleted != test.deleted {
+				t.Fatalf(
+					"expected kept[%t] deleted[%t], got kept[%t] deleted[%t]",
+					test.kept, test.deleted, kept, deleted,
+				)
+			}
+			wantKept, wantDeleted := []string(nil), []string(nil)
+			if test.kept {
+				wantKept = []string{"klb-rg"}
+			}
+			if test.deleted {
+				wantDeleted = []string{"klb-rg"}
+			}
+			if !reflect.DeepEqual(keeper.kept, wantKept) || !reflect.DeepEqual(keeper.deleted, wantDeleted) {
+				t.Errorf(
+					"expected kept%q deleted%q, got kept%q deleted%q",
+					wantKept, wantDeleted, keeper.kept, keeper.deleted,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package fixture
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Matrix has the values tests run with, each combination of
+// them runs as a fixture test. Empty dimensions are not expanded,
+// but at least one location is required.
+type Matrix struct {
+	Locations   []string
+	VMSizes     []string
+	StorageSKUs []string
+	AccessTiers []string
+	Caching     []string
+}
+
+// Combination is a combination of values of a matrix,
+// values of dimensions that are not expanded are empty.
+type Combination struct {
+	Location   string
+	VMSize     string
+	StorageSKU string
+	AccessTier string
+	Caching    string
+}
+
+// list is a comma separated list flag.
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(value string) error {
+	*l = strings.Split(value, ",")
+	return nil
+}
+
+// locations replace the locations of every matrix when set, so
+// matrix tests can also run on locations other than the default ones.
+var locations list
+
+// filters restrict the values of each dimension that are run.
+var filters struct {
+	vmsizes     list
+	storageskus list
+	accesstiers list
+	caching     list
+}
+
+// RunMatrix calls fixture.Run for each combination of the matrix
+// that is not filtered out, passing its values on F. The matrix runs
+// on the locations given by -locations, if any. Tests are named
+// after the values, like "Upload_eastus2_Standard_LRS_Hot", filtered
+// out combinations are skipped.
+func RunMatrix(
+	t *testing.T,
+	name string,
+	matrix Matrix,
+	timeout time.Duration,
+	testfunc Test,
+) {
+	matrix = matrix.relocated()
+	if len(matrix.Locations) == 0 {
+		t.Fatalf("matrix %s has no locations", name)
+	}
+	for _, combination := range matrix.combinations() {
+		c := combination
+		testname := c.testname(name)
+		if !c.selected() {
+			// WHY: skipped tests are listed by go test -v, so
+			// it is clear which combinations did not run.
+			t.Run(testname, func(t *testing.T) {
+				t.Skip("fixture: filtered out")
+			})
+			continue
+		}
+		Run(t, testname, timeout, c.Location, func(t *testing.T, f F) {
+			f.Combination = c
+			testfunc(t, f)
+		})
+	}
+}
+
+// relocated returns the matrix with the locations given by -locations.
+func (m Matrix) relocated() Matrix {
+	if len(locations) > 0 {
+		m.Locations = locations
+	}
+	return m
+}
+
+func (m Matrix) combinations() []Combination {
+	combinations := []Combination{{}}
+	expand := func(values []string, set func(*Combination, string)) {
+		if len(values) == 0 {
+			return
+		}
+		expanded := []Combination{}
+		for _, c := range combinations {
+			for _, value := range values {
+				set(&c, value)
+				expanded = append(expanded, c)
+			}
+		}
+		combinations = expanded
+	}
+	expand(m.Locations, func(c *Combination, v string) { c.Location = v })
+	expand(m.VMSizes, func(c *Combination, v string) { c.VMSize = v })
+	expand(m.StorageSKUs, func(c *Combination, v string) { c.StorageSKU = v })
+	expand(m.AccessTiers, func(c *Combination, v string) { c.AccessTier = v })
+	expand(m.Caching, func(c *Combination, v string) { c.Caching = v })
+	return combinations
+}
+
+func (c Combination) values() []string {
+	values := []string{}
+	for _, value := range []string{c.Location, c.VMSize, c.StorageSKU, c.AccessTier, c.Caching} {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// testname joins the values with underscores, since the
+// test name is also used on resource group and log file names.
+func (c Combination) testname(name string) string {
+	return strings.Join(append([]string{name}, c.values()...), "_")
+}
+
+func (c Combination) selected() bool {
+	return matches(filters.vmsizes, c.VMSize) &&
+		matches(filters.storageskus, c.StorageSKU) &&
+		matches(filters.accesstiers, c.AccessTier) &&
+		matches(filters.caching, c.Caching)
+}
+
+// matches is true when value is on the filter, values of
+// dimensions not expanded and empty filters match anything.
+func matches(filter list, value string) bool {
+	if len(filter) == 0 || value == "" {
+		return true
+	}
+	for _, f := range filter {
+		if strings.EqualFold(f, value) {
+			return true
+		}
+	}
+	return false
+}
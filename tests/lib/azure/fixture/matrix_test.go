@@ -0,0 +1,184 @@
+package fixture
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	type TestCase struct {
+		name   string
+		matrix Matrix
+		want   []string
+	}
+
+	tests := []TestCase{
+		{
+			name:   "OnlyLocations",
+			matrix: Matrix{Locations: []string{"eastus2", "westus2"}},
+			want:   []string{"Test_eastus2", "Test_westus2"},
+		},
+		{
+			name: "StorageSKUsAndTiers",
+			matrix: Matrix{
+				Locations:   []string{"eastus2"},
+				StorageSKUs: []string{"Standard_LRS", "Standard_GRS"},
+				AccessTiers: []string{"Cool", "Hot"},
+			},
+			want: []string{
+				"Test_eastus2_Standard_LRS_Cool",
+				"Test_eastus2_Standard_LRS_Hot",
+				"Test_eastus2_Standard_GRS_Cool",
+				"Test_eastus2_Standard_GRS_Hot",
+			},
+		},
+		{
+			name: "VMSizesAndCaching",
+			matrix: Matrix{
+				Locations: []string{"eastus2", "westus2"},
+				VMSizes:   []string{"Standard_DS4_v2"},
+				Caching:   []string{"None", "ReadOnly"},
+			},
+			want: []string{
+				"Test_eastus2_Standard_DS4_v2_None",
+				"Test_eastus2_Standard_DS4_v2_ReadOnly",
+				"Test_westus2_Standard_DS4_v2_None",
+				"Test_westus2_Standard_DS4_v2_ReadOnly",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := []string{}
+			for _, c := range test.matrix.combinations() {
+				got = append(got, c.testname("Test"))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected[%q], got[%q]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMatrixRelocated(t *testing.T) {
+	type TestCase struct {
+		name      string
+		locations list
+		want      []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "DefaultLocations",
+			want: []string{"eastus2"},
+		},
+		{
+			name:      "MoreLocations",
+			locations: list{"eastus2", "westus2"},
+			want:      []string{"eastus2", "westus2"},
+		},
+		{
+			name:      "OtherLocation",
+			locations: list{"westus2"},
+			want:      []string{"westus2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			locations = test.locations
+			defer func() {
+				locations = nil
+			}()
+
+			m := Matrix{Locations: []string{"eastus2"}, VMSizes: []string{"Standard_DS4_v2"}}
+			got := m.relocated()
+			if !reflect.DeepEqual(got.Locations, test.want) {
+				t.Errorf("expected[%q], got[%q]", test.want, got.Locations)
+			}
+			if !reflect.DeepEqual(got.VMSizes, m.VMSizes) {
+				t.Errorf("expected[%q], got[%q]", m.VMSizes, got.VMSizes)
+			}
+		})
+	}
+}
+
+func TestCombinationSelected(t *testing.T) {
+	type TestCase struct {
+		name        string
+		vmsizes     list
+		storageskus list
+		combination Combination
+		want        bool
+	}
+
+	tests := []TestCase{
+		{
+			name:        "NoFilters",
+			combination: Combination{Location: "eastus2", StorageSKU: "Standard_LRS"},
+			want:        true,
+		},
+		{
+			name:        "VMSizeMatches",
+			vmsizes:     list{"Standard_DS2_v2", "Standard_DS4_v2"},
+			combination: Combination{Location: "eastus2", VMSize: "Standard_DS4_v2"},
+			want:        true,
+		},
+		{
+			name:        "VMSizeIgnoresCase",
+			vmsizes:     list{"standard_ds4_v2"},
+			combination: Combination{Location: "eastus2", VMSize: "Standard_DS4_v2"},
+			want:        true,
+		},
+		{
+			name:        "VMSizeFilteredOut",
+			vmsizes:     list{"Standard_DS2_v2"},
+			combination: Combination{Location: "eastus2", VMSize: "Standard_DS4_v2"},
+			want:        false,
+		},
+		{
+			name:        "AnyFilterFilters",
+			vmsizes:     list{"Standard_DS4_v2"},
+			storageskus: list{"Standard_GRS"},
+			combination: Combination{Location: "eastus2", VMSize: "Standard_DS4_v2", StorageSKU: "Standard_LRS"},
+			want:        false,
+		},
+		{
+			name:        "DimensionNotExpanded",
+			storageskus: list{"Standard_GRS"},
+			combination: Combination{Location: "eastus2"},
+			want:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters.vmsizes = test.vmsizes
+			filters.storageskus = test.storageskus
+			defer func() {
+				filters.vmsizes = nil
+				filters.storageskus = nil
+			}()
+
+			got := test.combination.selected()
+			if got != test.want {
+				t.Errorf("expected selected[%t], got[%t]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	var l list
+	err := l.Set("eastus2,westus2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l, list{"eastus2", "westus2"}) {
+		t.Errorf("expected[eastus2 westus2], got[%q]", l)
+	}
+	if l.String() != "eastus2,westus2" {
+		t.Errorf("expected[eastus2,westus2], got[%s]", l.String())
+	}
+}
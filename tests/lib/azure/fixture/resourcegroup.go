@@ -3,18 +3,19 @@ package fixture
 import (
 	"context"
 	"fmt"
-	"log"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/arm/resources/resources"
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/ratelimit"
 	"github.com/NeowayLabs/klb/tests/lib/retrier"
 )
 
 type ResourceGroup struct {
 	client  resources.GroupsClient
 	ctx     context.Context
-	logger  *log.Logger
+	logger  *testlog.Logger
 	retrier *retrier.Retrier
 }
 
@@ -22,28 +23,38 @@ func NewResourceGroup(
 	ctx context.Context,
 	t *testing.T,
 	s *Session,
-	logger *log.Logger,
+	logger *testlog.Logger,
 ) *ResourceGroup {
 	rg := &ResourceGroup{
 		client:  resources.NewGroupsClient(s.SubscriptionID),
 		ctx:     ctx,
 		logger:  logger,
-		retrier: retrier.New(ctx, t, logger),
+		retrier: retrier.New(ctx, t, logger, retrier.Fast),
 	}
+	// WHY: AssertExists polls groups created by scripts
+	rg.retrier.SetClassifier(retrier.Polling)
 	rg.client.Authorizer = s.Token
+	rg.client.Sender = ratelimit.Subscription.Sender(ctx, t.Name())
 	return rg
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (r *ResourceGroup) clientFor(ctx context.Context) resources.GroupsClient {
+	client := r.client
+	client.Sender = bindSender(ctx, r.client.Sender)
+	return client
+}
+
 func (r *ResourceGroup) AssertExists(t *testing.T, name string) {
-	r.retrier.Run("ResourceGroup.AssertExists", func() error {
-		_, err := r.client.CheckExistence(name)
+	r.retrier.RunContext("ResourceGroup.AssertExists", func(ctx context.Context) error {
+		_, err := r.clientFor(ctx).CheckExistence(name)
 		return err
 	})
 }
 
 func (r *ResourceGroup) AssertDeleted(t *testing.T, name string) {
-	r.retrier.Run("ResourceGroup.AssertDeleted", func() error {
-		_, err := r.client.Get(name)
+	r.retrier.RunContext("ResourceGroup.AssertDeleted", func(ctx context.Context) error {
+		_, err := r.clientFor(ctx).Get(name)
 		if err == nil {
 			return fmt.Errorf("resource group: %q still exists", name)
 		}
@@ -51,15 +62,53 @@ func (r *ResourceGroup) AssertDeleted(t *testing.T, name string) {
 	})
 }
 
-func (r *ResourceGroup) Create(t *testing.T, name string, location string) {
-	r.retrier.Run("ResourceGroup.Create", func() error {
-		_, err := r.client.CreateOrUpdate(name, resources.Group{
+func (r *ResourceGroup) Create(
+	t *testing.T,
+	name string,
+	location string,
+	tags map[string]string,
+) {
+	r.retrier.RunContext("ResourceGroup.Create", func(ctx context.Context) error {
+		_, err := r.clientFor(ctx).CreateOrUpdate(name, resources.Group{
 			Location: &location,
+			Tags:     azureTags(tags),
 		})
 		return err
 	})
 }
 
+// Keep tags the resource group with the time it expires at, after
+// that it is not needed anymore and can be deleted by anyone.
+// The given tags replace the current ones. It returns an error
+// if the tags could not be saved, so the caller can delete the
+// resource group instead of leaving it untagged.
+func (r *ResourceGroup) Keep(
+	t *testing.T,
+	name string,
+	tags map[string]string,
+	expiresAt time.Time,
+) error {
+	err := r.retrier.RunE(r.ctx, "ResourceGroup.Keep", func(ctx context.Context) error {
+		_, err := r.clientFor(ctx).Patch(name, resources.Group{
+			Tags: azureTags(keptTags(tags, expiresAt)),
+		})
+		return err
+	})
+	if err != nil {
+		r.logger.Printf("ResourceGroup.Keep: unable to tag %q: %s", name, err)
+	}
+	return err
+}
+
+func azureTags(tags map[string]string) *map[string]*string {
+	res := map[string]*string{}
+	for k, v := range tags {
+		value := v
+		res[k] = &value
+	}
+	return &res
+}
+
 // Delete will delete an existing resource group.
 // It will NOT wait more than timeout to the resource group to be
 // completely destroyed because that takes a VERY long time
@@ -67,22 +116,23 @@ func (r *ResourceGroup) Create(t *testing.T, name string, location string) {
 //
 // Exceeding the given timeout is not an error, if it happens it will check
 // if the resource group is on deprovisioning state.
-func (r *ResourceGroup) Delete(t *testing.T, name string) {
+// It returns false if the deletion could not be confirmed.
+func (r *ResourceGroup) Delete(t *testing.T, name string) bool {
 	r.logger.Printf("ResourceGroup.Delete: deleting %q", name)
 
-	r.client.Delete(name, r.ctx.Done())
+	r.clientFor(r.ctx).Delete(name, r.ctx.Done())
 
-	r.checkDeleted(t, name)
+	return r.checkDeleted(t, name)
 }
 
-func (r *ResourceGroup) checkDeleted(t *testing.T, name string) {
+func (r *ResourceGroup) checkDeleted(t *testing.T, name string) bool {
 	deadline := time.Now().Add(30 * time.Second)
 
 	for time.Now().Before(deadline) {
-		resgroup, err := r.client.Get(name)
+		resgroup, err := r.clientFor(r.ctx).Get(name)
 		if err != nil {
 			r.logger.Printf("ResourceGroup.Delete finished")
-			return
+			return true
 		}
 		r.logger.Printf("ResourceGroup.Delete: still exists, checking if deprovisioning")
 		if resgroup.Properties == nil {
@@ -95,9 +145,11 @@ func (r *ResourceGroup) checkDeleted(t *testing.T, name string) {
 		expectedState := "Deleting"
 		if provisioningState == expectedState {
 			r.logger.Printf("ResourceGroup.Delete: resgroup is deprovisioning, should be ok")
-			return
+			return true
 		}
 		r.logger.Printf("ResourceGroup:Delete: resgroup not deleting or deleted yet")
 		time.Sleep(time.Second)
 	}
+	r.logger.Printf("ResourceGroup.Delete: unable to confirm %q was deleted", name)
+	return false
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	restazure "github.com/Azure/go-autorest/autorest/azure"
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
 )
 
 type Session struct {
@@ -53,6 +54,34 @@ func getenv(t *testing.T, varname string) string {
 	return val
 }
 
+// newOfflineSession creates a session with placeholder credentials
+// for tests replaying cassettes, its token is never refreshed, so
+// it never reaches Azure.
+func newOfflineSession(t *testing.T) *Session {
+	session := &Session{
+		ClientID:         "klb-tests-offline-client-id",
+		ClientSecret:     "klb-tests-offline-client-secret",
+		SubscriptionID:   "00000000-0000-0000-0000-000000000000",
+		TenantID:         "klb-tests-offline-tenant-id",
+		ServicePrincipal: "klb-tests-offline-service-principal",
+	}
+	oauthConfig, err := restazure.PublicCloud.OAuthConfigForTenant(session.TenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Token, err = restazure.NewServicePrincipalTokenFromManualToken(
+		*oauthConfig,
+		session.ClientID,
+		restazure.PublicCloud.ResourceManagerEndpoint,
+		restazure.Token{AccessToken: "klb-tests-offline-token", Type: "Bearer"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Token.SetAutoRefresh(false)
+	return session
+}
+
 func NewSession(t *testing.T) *Session {
 	session := &Session{
 		ClientID:         getenv(t, "AZURE_CLIENT_ID"),
@@ -61,6 +90,7 @@ func NewSession(t *testing.T) *Session {
 		TenantID:         getenv(t, "AZURE_TENANT_ID"),
 		ServicePrincipal: getenv(t, "AZURE_SERVICE_PRINCIPAL"),
 	}
+	testlog.AddSecret(session.ClientSecret)
 	session.generateToken(t)
 	return session
 }
@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -21,15 +22,23 @@ func NewNic(f fixture.F) *Nic {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (nic *Nic) clientFor(ctx context.Context) network.InterfacesClient {
+	client := nic.client
+	client.Sender = nic.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if nic exists in the resource group.
 // Fail tests otherwise.
 func (nic *Nic) AssertExists(t *testing.T, name string, privateIP string) {
-	nic.f.Retrier.Run(newID("Nic", "AssertExists", name), func() error {
+	nic.f.Retrier.RunContext(newID("Nic", "AssertExists", name), func(ctx context.Context) error {
 
-		ipconfigs, err := nic.GetIPConfigs(t, name)
+		ipconfigs, err := nic.getIPConfigs(ctx, t, nic.f.ResGroupName, name)
 		if err != nil {
 			return err
 		}
@@ -64,14 +73,14 @@ func parseNICID(t *testing.T, nicID string) (string, string) {
 
 func (nic *Nic) GetIPConfigsByID(t *testing.T, ID string) ([]NicIPConfig, error) {
 	resgroup, nicname := parseNICID(t, ID)
-	return nic.getIPConfigs(t, resgroup, nicname)
+	return nic.getIPConfigs(nic.f.Ctx, t, resgroup, nicname)
 }
 
 func (nic *Nic) GetIPConfigs(t *testing.T, name string) ([]NicIPConfig, error) {
-	return nic.getIPConfigs(t, nic.f.ResGroupName, name)
+	return nic.getIPConfigs(nic.f.Ctx, t, nic.f.ResGroupName, name)
 }
 
-func (nic *Nic) getIPConfigs(t *testing.T, resgroup string, nicname string) ([]NicIPConfig, error) {
+func (nic *Nic) getIPConfigs(ctx context.Context, t *testing.T, resgroup string, nicname string) ([]NicIPConfig, error) {
 
 	var ipconfigs []NicIPConfig
 
@@ -79,7 +88,7 @@ func (nic *Nic) getIPConfigs(t *testing.T, resgroup string, nicname string) ([]N
 		return fmt.Errorf("Nic.GetInfo: error[%s]", err)
 	}
 
-	n, err := nic.client.Get(resgroup, nicname, "")
+	n, err := nic.clientFor(ctx).Get(resgroup, nicname, "")
 	if err != nil {
 		return []NicIPConfig{}, wraperror(err)
 	}
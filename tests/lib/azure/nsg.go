@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/arm/network"
@@ -18,14 +19,22 @@ func NewNsg(f fixture.F) *Nsg {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (nsg *Nsg) clientFor(ctx context.Context) network.SecurityGroupsClient {
+	client := nsg.client
+	client.Sender = nsg.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if network security groups exists in the resource group.
 // Fail tests otherwise.
 func (nsg *Nsg) AssertExists(t *testing.T, name string) {
-	nsg.f.Retrier.Run(newID("Nsg", "AssertExists", name), func() error {
-		_, err := nsg.client.Get(nsg.f.ResGroupName, name, "")
+	nsg.f.Retrier.RunContext(newID("Nsg", "AssertExists", name), func(ctx context.Context) error {
+		_, err := nsg.clientFor(ctx).Get(nsg.f.ResGroupName, name, "")
 		return err
 	})
 }
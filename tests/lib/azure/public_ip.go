@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -19,14 +20,22 @@ func NewPublicIp(f fixture.F) *PublicIp {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (publicIp *PublicIp) clientFor(ctx context.Context) network.PublicIPAddressesClient {
+	client := publicIp.client
+	client.Sender = publicIp.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if publicIp exists in the resource group.
 // Fail tests otherwise.
 func (publicIp *PublicIp) AssertExists(t *testing.T, name string) {
-	publicIp.f.Retrier.Run(newID("PublicIp", "AssertExists", name), func() error {
-		n, err := publicIp.client.Get(publicIp.f.ResGroupName, name, "")
+	publicIp.f.Retrier.RunContext(newID("PublicIp", "AssertExists", name), func(ctx context.Context) error {
+		n, err := publicIp.clientFor(ctx).Get(publicIp.f.ResGroupName, name, "")
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -19,9 +20,17 @@ func NewRoute(f fixture.F) *Route {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (r *Route) clientFor(ctx context.Context) network.RoutesClient {
+	client := r.client
+	client.Sender = r.f.SenderFor(ctx)
+	return client
+}
+
 // checkAddressHoptypeProperties checks if address and hoptype properties exists in route.
 func (r *Route) checkAddressHoptypeProperties(route network.Route, address, hoptype string) error {
 	if route.RoutePropertiesFormat == nil {
@@ -48,8 +57,8 @@ func (r *Route) checkAddressHoptypeProperties(route network.Route, address, hopt
 // AssertRouteExists checks if a route exists in the resource group.
 // Fail tests otherwise.
 func (r *Route) AssertRouteExists(t *testing.T, routeTableName, routeName, address, hoptype string) {
-	r.f.Retrier.Run(newID("Route", "AssertExists", routeName), func() error {
-		route, err := r.client.Get(r.f.ResGroupName, routeTableName, routeName)
+	r.f.Retrier.RunContext(newID("Route", "AssertExists", routeName), func(ctx context.Context) error {
+		route, err := r.clientFor(ctx).Get(r.f.ResGroupName, routeTableName, routeName)
 		if err != nil {
 			return err
 		}
@@ -61,8 +70,8 @@ func (r *Route) AssertRouteExists(t *testing.T, routeTableName, routeName, addre
 // AssertVirtualApplianceRouteExists checks if a route of VirtualAppliance hop type exists in the resource group.
 // Fail tests otherwise.
 func (r *Route) AssertVirtualApplianceRouteExists(t *testing.T, routeTableName, routeName, address, hoptype, hopaddress string) {
-	r.f.Retrier.Run(newID("Route", "AssertExists", routeName), func() error {
-		route, err := r.client.Get(r.f.ResGroupName, routeTableName, routeName)
+	r.f.Retrier.RunContext(newID("Route", "AssertExists", routeName), func(ctx context.Context) error {
+		route, err := r.clientFor(ctx).Get(r.f.ResGroupName, routeTableName, routeName)
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/arm/network"
@@ -18,14 +19,22 @@ func NewRouteTable(f fixture.F) *RouteTable {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (r *RouteTable) clientFor(ctx context.Context) network.RouteTablesClient {
+	client := r.client
+	client.Sender = r.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if a route table exists in the resource group.
 // Fail tests otherwise.
 func (r *RouteTable) AssertExists(t *testing.T, name string) {
-	r.f.Retrier.Run(newID("RouteTable", "AssertExists", name), func() error {
-		_, err := r.client.Get(r.f.ResGroupName, name, "")
+	r.f.Retrier.RunContext(newID("RouteTable", "AssertExists", name), func(ctx context.Context) error {
+		_, err := r.clientFor(ctx).Get(r.f.ResGroupName, name, "")
 		return err
 	})
 }
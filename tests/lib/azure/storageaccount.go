@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/arm/storage"
@@ -28,13 +29,21 @@ func NewStorageAccounts(f fixture.F) *StorageAccounts {
 		f:      f,
 	}
 	sa.client.Authorizer = f.Session.Token
+	sa.client.Sender = f.Sender
 	return sa
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (s *StorageAccounts) clientFor(ctx context.Context) storage.AccountsClient {
+	client := s.client
+	client.Sender = s.f.SenderFor(ctx)
+	return client
+}
+
 // Account gets the storage account with the given name.
 // Fails test in case of any errors.
 func (s *StorageAccounts) Account(t *testing.T, name string) StorageAccount {
-	acc, err := s.client.GetProperties(s.f.ResGroupName, name)
+	acc, err := s.clientFor(s.f.Ctx).GetProperties(s.f.ResGroupName, name)
 	if err != nil {
 		t.Fatalf("Account:error[%s]", err)
 	}
@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"testing"
@@ -20,14 +21,22 @@ func NewSubnet(f fixture.F) *Subnet {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (s *Subnet) clientFor(ctx context.Context) network.SubnetsClient {
+	client := s.client
+	client.Sender = s.f.SenderFor(ctx)
+	return client
+}
+
 // AssertExists checks if subnet exists in the resource group.
 // Fail tests otherwise.
 func (s *Subnet) AssertExists(t *testing.T, vnetName, subnetName, address, nsg string) {
-	s.f.Retrier.Run(newID("Subnet", "AssertExists", subnetName), func() error {
-		subnet, err := s.client.Get(s.f.ResGroupName, vnetName, subnetName, "")
+	s.f.Retrier.RunContext(newID("Subnet", "AssertExists", subnetName), func(ctx context.Context) error {
+		subnet, err := s.clientFor(ctx).Get(s.f.ResGroupName, vnetName, subnetName, "")
 		if err != nil {
 			return err
 		}
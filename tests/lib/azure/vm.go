@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -36,9 +37,17 @@ func NewVM(f fixture.F) *VM {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (vm *VM) clientFor(ctx context.Context) compute.VirtualMachinesClient {
+	client := vm.client
+	client.Sender = vm.f.SenderFor(ctx)
+	return client
+}
+
 func (vm *VM) IPs(t *testing.T, vmname string) []string {
 	abortonerr := func(err error) {
 		if err != nil {
@@ -46,7 +55,7 @@ func (vm *VM) IPs(t *testing.T, vmname string) []string {
 		}
 	}
 
-	v, err := vm.client.Get(vm.f.ResGroupName, vmname, "")
+	v, err := vm.clientFor(vm.f.Ctx).Get(vm.f.ResGroupName, vmname, "")
 	abortonerr(err)
 
 	if v.VirtualMachineProperties == nil {
@@ -85,8 +94,8 @@ func (vm *VM) OsDisk(t *testing.T, vmname string) VMOsDisk {
 
 	var osdisk *VMOsDisk
 
-	vm.f.Retrier.Run(newID("VM", "OsDisk", vmname), func() error {
-		v, err := vm.client.Get(vm.f.ResGroupName, vmname, "")
+	vm.f.Retrier.RunContext(newID("VM", "OsDisk", vmname), func(ctx context.Context) error {
+		v, err := vm.clientFor(ctx).Get(vm.f.ResGroupName, vmname, "")
 		if err != nil {
 			return err
 		}
@@ -131,8 +140,8 @@ func (vm *VM) DataDisks(t *testing.T, vmname string) []VMDataDisk {
 
 	disksinfo := []VMDataDisk{}
 
-	vm.f.Retrier.Run(newID("VM", "DataDisks", vmname), func() error {
-		v, err := vm.client.Get(vm.f.ResGroupName, vmname, "")
+	vm.f.Retrier.RunContext(newID("VM", "DataDisks", vmname), func(ctx context.Context) error {
+		v, err := vm.clientFor(ctx).Get(vm.f.ResGroupName, vmname, "")
 		if err != nil {
 			return err
 		}
@@ -185,7 +194,7 @@ func (vm *VM) AssertAttachedDataDisk(
 	storageAccountType string,
 	caching string,
 ) {
-	vm.f.Retrier.Run(newID("VM", "AssertAttachedDataDisk", vmname), func() error {
+	vm.f.Retrier.RunContext(newID("VM", "AssertAttachedDataDisk", vmname), func(ctx context.Context) error {
 
 		disks := vm.DataDisks(t, vmname)
 
@@ -214,8 +223,8 @@ func (vm *VM) AssertAttachedDataDisk(
 // AssertExistsByName checks if VM exists in the resource group
 // based only on its name. Fail tests otherwise.
 func (vm *VM) AssertExistsByName(t *testing.T, name string) {
-	vm.f.Retrier.Run(newID("VM", "AssertExistsByName", name), func() error {
-		_, err := vm.client.Get(vm.f.ResGroupName, name, "")
+	vm.f.Retrier.RunContext(newID("VM", "AssertExistsByName", name), func(ctx context.Context) error {
+		_, err := vm.clientFor(ctx).Get(vm.f.ResGroupName, name, "")
 		if err != nil {
 			return fmt.Errorf("unable to find vm %q, error: %s", name, err)
 		}
@@ -233,8 +242,8 @@ func (vm *VM) AssertExists(
 	expectedNic string,
 	expectedTags string,
 ) {
-	vm.f.Retrier.Run(newID("VM", "AssertExists", name), func() error {
-		v, err := vm.client.Get(vm.f.ResGroupName, name, "")
+	vm.f.Retrier.RunContext(newID("VM", "AssertExists", name), func(ctx context.Context) error {
+		v, err := vm.clientFor(ctx).Get(vm.f.ResGroupName, name, "")
 		if err != nil {
 			return err
 		}
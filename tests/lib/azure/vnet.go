@@ -1,12 +1,14 @@
 package azure
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/arm/network"
+	"github.com/NeowayLabs/klb/tests/lib/assert"
 	"github.com/NeowayLabs/klb/tests/lib/azure/fixture"
 )
 
@@ -21,9 +23,17 @@ func NewVnet(f fixture.F) *Vnet {
 		f:      f,
 	}
 	as.client.Authorizer = f.Session.Token
+	as.client.Sender = f.Sender
 	return as
 }
 
+// clientFor returns the client sending requests bound to ctx.
+func (vnet *Vnet) clientFor(ctx context.Context) network.VirtualNetworksClient {
+	client := vnet.client
+	client.Sender = vnet.f.SenderFor(ctx)
+	return client
+}
+
 func validateVnetDnsServers(
 	t *testing.T,
 	expectedDnsServers []string,
@@ -37,25 +47,8 @@ func validateVnetDnsServers(
 	}
 
 	dnsServers := *net.DhcpOptions.DNSServers
-	err := fmt.Errorf(
-		"expected DNS servers[%s], got [%s]",
-		expectedDnsServers,
-		dnsServers,
-	)
-	if len(dnsServers) != len(expectedDnsServers) {
-		return err
-	}
-	for _, expectedDnsServer := range expectedDnsServers {
-		found := false
-		for _, dnsServer := range dnsServers {
-			if dnsServer == expectedDnsServer {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return err
-		}
+	if diff := assert.UnorderedDiff(expectedDnsServers, dnsServers); diff != "" {
+		return fmt.Errorf("unexpected DNS servers:\n%s", diff)
 	}
 	return nil
 }
@@ -69,8 +62,8 @@ func (vnet *Vnet) AssertExists(
 	expectedRouteTable string,
 	expectedDnsServers []string,
 ) {
-	vnet.f.Retrier.Run(newID("Vnet", "AssertExists", name), func() error {
-		net, err := vnet.client.Get(vnet.f.ResGroupName, name, "")
+	vnet.f.Retrier.RunContext(newID("Vnet", "AssertExists", name), func(ctx context.Context) error {
+		net, err := vnet.clientFor(ctx).Get(vnet.f.ResGroupName, name, "")
 		if err != nil {
 			return err
 		}
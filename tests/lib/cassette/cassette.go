@@ -0,0 +1,299 @@
+// Package cassette records the az and azure commands and the
+// Azure API requests made by a test run, with secrets scrubbed,
+// replaying them on the next runs without touching the network.
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// Dir is where cassettes are saved, relative to the test package.
+const Dir = "./testdata/cassettes"
+
+// Commands are the commands recorded on cassettes.
+var Commands = []string{"az", "azure"}
+
+// uniqueName matches the unique names generated by the tests,
+// like the ones from fixture.NewUniqueName.
+var uniqueName = regexp.MustCompile(`klb-[A-Za-z0-9_]+-[0-9]{9,}-[0-9]+`)
+
+const scrubbed = "{{scrubbed}}"
+
+// Recording has the commands and requests recorded from a
+// test run, on the order they happened.
+type Recording struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a command or a request recorded on a cassette,
+// only one of them is set.
+type Interaction struct {
+	Command *Command `json:"command,omitempty"`
+	Request *Request `json:"request,omitempty"`
+}
+
+// Command is a command and its results.
+type Command struct {
+	Args     []string `json:"args"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+}
+
+// Request is an Azure API request and its response,
+// only the response status and body are recorded.
+type Request struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Body       string `json:"body,omitempty"`
+	StatusCode int    `json:"status_code"`
+	Response   string `json:"response"`
+}
+
+// Cassette records or replays a test run, it is safe to
+// use it concurrently.
+type Cassette struct {
+	mutex     sync.Mutex
+	path      string
+	replaying bool
+	recording Recording
+	used      []bool
+	// last is the index of the last replayed command.
+	last int
+
+	// context has the values of the current environment
+	// that are replaced by placeholders, like secrets.
+	context map[string]string
+	// names has the placeholders of unique names, on the
+	// order they have been used by commands and requests.
+	names map[string]string
+}
+
+// Open opens the cassette of the given test at dir, it replays the
+// recorded test run if the cassette exists, otherwise it records a
+// new one that is saved by Save. To record a cassette again just
+// remove it.
+//
+// Values of the context and known secrets like storage account keys
+// and SAS tokens are replaced by placeholders, so they are never
+// saved. Unique names generated by the tests are also replaced by
+// placeholders, so the test must run commands and send requests
+// with new names on the same order every run.
+func Open(dir string, testname string) (*Cassette, error) {
+	c := &Cassette{
+		path:    filepath.Join(dir, testname, "cassette.json"),
+		last:    -1,
+		context: map[string]string{},
+		names:   map[string]string{},
+	}
+
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		c.recording.Interactions = []Interaction{}
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette[%s]: %s", c.path, err)
+	}
+	err = json.Unmarshal(data, &c.recording)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cassette[%s]: %s", c.path, err)
+	}
+	c.replaying = true
+	c.used = make([]bool, len(c.recording.Interactions))
+	return c, nil
+}
+
+// Path is where the cassette is saved.
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+// Replaying tells if the cassette is replaying a recorded test run.
+func (c *Cassette) Replaying() bool {
+	return c.replaying
+}
+
+// IsCommand tells if the command is recorded on cassettes.
+func IsCommand(name string) bool {
+	for _, cmd := range Commands {
+		if name == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// SetContext sets the values from the given environment, like
+// "AZURE_CLIENT_ID=id", that are replaced by placeholders.
+func (c *Cassette) SetContext(env []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, envvar := range env {
+		parsed := strings.SplitN(envvar, "=", 2)
+		if len(parsed) != 2 || parsed[0] == "PATH" {
+			continue
+		}
+		// WHY: short values would replace random parts of the output
+		if len(parsed[1]) < 4 {
+			continue
+		}
+		c.context[parsed[0]] = parsed[1]
+	}
+}
+
+// Record records the given commands, commands not recorded
+// on cassettes are ignored.
+func (c *Cassette) Record(commands []Command) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, cmd := range commands {
+		if len(cmd.Args) == 0 || !IsCommand(cmd.Args[0]) {
+			continue
+		}
+		c.recording.Interactions = append(c.recording.Interactions, Interaction{
+			Command: &Command{
+				Args:     c.scrubArgs(cmd.Args),
+				Stdout:   c.scrubOutput(cmd.Stdout),
+				Stderr:   c.scrubOutput(cmd.Stderr),
+				ExitCode: cmd.ExitCode,
+			},
+		})
+	}
+}
+
+// Replay finds the first command not replayed yet with the
+// same args, returning it with the placeholders restored.
+func (c *Cassette) Replay(args []string) (Command, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	scrubbedArgs := c.scrubArgs(args)
+	for i, interaction := range c.recording.Interactions {
+		cmd := interaction.Command
+		if cmd == nil || c.used[i] || !equalArgs(cmd.Args, scrubbedArgs) {
+			continue
+		}
+		c.used[i] = true
+		c.last = i
+		return Command{
+			Args:     args,
+			Stdout:   c.restore(cmd.Stdout),
+			Stderr:   c.restore(cmd.Stderr),
+			ExitCode: cmd.ExitCode,
+		}, true
+	}
+	return Command{}, false
+}
+
+// Save saves the recorded test run, it does nothing when replaying.
+func (c *Cassette) Save() error {
+	if c.replaying {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data, err := json.MarshalIndent(c.recording, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error encoding cassette: %s", err)
+	}
+	err = os.MkdirAll(filepath.Dir(c.path), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating cassette dir: %s", err)
+	}
+	err = ioutil.WriteFile(c.path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error saving cassette: %s", err)
+	}
+	return nil
+}
+
+// scrubArgs replaces secrets and context values by placeholders,
+// new unique names get new placeholders.
+func (c *Cassette) scrubArgs(args []string) []string {
+	res := []string{}
+	for _, arg := range args {
+		arg = uniqueName.ReplaceAllStringFunc(arg, func(name string) string {
+			placeholder, ok := c.names[name]
+			if !ok {
+				placeholder = fmt.Sprintf("{{unique-%d}}", len(c.names)+1)
+				c.names[name] = placeholder
+			}
+			return placeholder
+		})
+		res = append(res, c.scrub(arg))
+	}
+	return res
+}
+
+// scrubOutput replaces secrets, context values and known unique
+// names by placeholders.
+func (c *Cassette) scrubOutput(output string) string {
+	output = uniqueName.ReplaceAllStringFunc(output, func(name string) string {
+		if placeholder, ok := c.names[name]; ok {
+			return placeholder
+		}
+		return name
+	})
+	return c.scrub(output)
+}
+
+func (c *Cassette) scrub(s string) string {
+	for _, name := range c.contextNames() {
+		s = strings.Replace(s, c.context[name], "{{"+name+"}}", -1)
+	}
+	return testlog.RedactWith(s, scrubbed)
+}
+
+// restore replaces placeholders by the current values.
+func (c *Cassette) restore(s string) string {
+	for name, value := range c.context {
+		s = strings.Replace(s, "{{"+name+"}}", value, -1)
+	}
+	for name, placeholder := range c.names {
+		s = strings.Replace(s, placeholder, name, -1)
+	}
+	return s
+}
+
+// contextNames returns the context names sorted by the size of their
+// values, replacing longer values first avoids partial replacements.
+func (c *Cassette) contextNames() []string {
+	names := []string{}
+	for name := range c.context {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := c.context[names[i]], c.context[names[j]]
+		if len(a) == len(b) {
+			return names[i] < names[j]
+		}
+		return len(a) > len(b)
+	})
+	return names
+}
+
+func equalArgs(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package cassette
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const resgroup = "klb-TestVM-1508439367-7943"
+
+func TestRecordScrubs(t *testing.T) {
+	c := newCassette(t, t.Name(), "AZURE_CLIENT_SECRET=hunter22", "AZURE_SUBSCRIPTION_ID=sub-id")
+	c.Record([]Command{
+		{
+			Args:   []string{"azure", "login", "-p", "hunter22"},
+			Stdout: "logged in",
+		},
+		{
+			Args:   []string{"az", "group", "show", "--name", resgroup},
+			Stdout: "/subscriptions/sub-id/resourceGroups/" + resgroup,
+		},
+		{
+			Args: []string{"jq", ".id"},
+		},
+	})
+
+	want := []Command{
+		{
+			Args:   []string{"azure", "login", "-p", "{{AZURE_CLIENT_SECRET}}"},
+			Stdout: "logged in",
+		},
+		{
+			Args:   []string{"az", "group", "show", "--name", "{{unique-1}}"},
+			Stdout: "/subscriptions/{{AZURE_SUBSCRIPTION_ID}}/resourceGroups/{{unique-1}}",
+		},
+	}
+	got := c.recording.Interactions
+	if len(got) != len(want) {
+		t.Fatalf("expected %d commands, got %+v", len(want), got)
+	}
+	for i, interaction := range got {
+		cmd := *interaction.Command
+		if strings.Join(cmd.Args, " ") != strings.Join(want[i].Args, " ") || cmd.Stdout != want[i].Stdout {
+			t.Errorf("command[%d]: expected %+v, got %+v", i, want[i], cmd)
+		}
+	}
+}
+
+func TestReplayRestores(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := openCassette(t, dir, "AZURE_SUBSCRIPTION_ID=sub-id")
+	c.Record([]Command{{
+		Args:   []string{"az", "group", "show", "--name", resgroup},
+		Stdout: "/subscriptions/sub-id/resourceGroups/" + resgroup,
+	}})
+	save(t, c)
+
+	replayed := openCassette(t, dir, "AZURE_SUBSCRIPTION_ID=offline-id")
+	if !replayed.Replaying() {
+		t.Fatal("expected cassette to be replaying")
+	}
+	newgroup := "klb-TestVM-1608439367-1"
+	cmd, ok := replayed.Replay([]string{"az", "group", "show", "--name", newgroup})
+	if !ok {
+		t.Fatal("expected command to be replayed")
+	}
+	want := "/subscriptions/offline-id/resourceGroups/" + newgroup
+	if cmd.Stdout != want {
+		t.Errorf("expected stdout[%s], got[%s]", want, cmd.Stdout)
+	}
+	_, ok = replayed.Replay([]string{"az", "group", "show", "--name", newgroup})
+	if ok {
+		t.Error("expected command to be replayed only once")
+	}
+}
+
+func TestSenderReplay(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	url := "https://management.azure.com/subscriptions/sub-id/resourceGroups/" + resgroup
+	responses := []string{"created", "created", "tagged"}
+	c := openCassette(t, dir, "AZURE_SUBSCRIPTION_ID=sub-id")
+	sender := c.Sender(autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		body := responses[0]
+		responses = responses[1:]
+		return c.response(r, http.StatusOK, body), nil
+	}))
+	c.Record([]Command{{Args: []string{"az", "group", "create", "--name", resgroup}}})
+	send(t, sender, "GET", url)
+	send(t, sender, "GET", url)
+	c.Record([]Command{{Args: []string{"az", "group", "update", "--name", resgroup}}})
+	send(t, sender, "GET", url)
+	save(t, c)
+
+	replayed := openCassette(t, dir, "AZURE_SUBSCRIPTION_ID=offline-id")
+	sender = replayed.Sender(nil)
+	newgroup := "klb-TestVM-1608439367-1"
+	newurl := "https://management.azure.com/subscriptions/offline-id/resourceGroups/" + newgroup
+	replay := func(t *testing.T, args ...string) {
+		_, ok := replayed.Replay(args)
+		if !ok {
+			t.Fatalf("expected command %q to be replayed", args)
+		}
+	}
+
+	type TestCase struct {
+		name   string
+		before func(t *testing.T)
+		method string
+		url    string
+		status int
+		body   string
+	}
+
+	tests := []TestCase{
+		{
+			name:   "AfterCommand",
+			before: func(t *testing.T) { replay(t, "az", "group", "create", "--name", newgroup) },
+			method: "GET",
+			url:    newurl,
+			status: http.StatusOK,
+			body:   "created",
+		},
+		{
+			name:   "RepeatsLastResponse",
+			method: "GET",
+			url:    newurl,
+			status: http.StatusOK,
+			body:   "created",
+		},
+		{
+			name:   "StateOfNextCommand",
+			before: func(t *testing.T) { replay(t, "az", "group", "update", "--name", newgroup) },
+			method: "GET",
+			url:    newurl,
+			status: http.StatusOK,
+			body:   "tagged",
+		},
+		{
+			name:   "UnknownRequest",
+			method: "DELETE",
+			url:    newurl,
+			status: http.StatusBadRequest,
+			body:   `{"error":{"code":"CassetteMiss"`,
+		},
+	}
+
+	// WHY: each case depends on the commands replayed by the previous ones
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.before != nil {
+				test.before(t)
+			}
+			status, body := send(t, sender, test.method, test.url)
+			if status != test.status || !strings.HasPrefix(body, test.body) {
+				t.Errorf(
+					"expected status %d body[%s], got status %d body[%s]",
+					test.status, test.body, status, body,
+				)
+			}
+		})
+	}
+}
+
+func newCassette(t *testing.T, testname string, env ...string) *Cassette {
+	c, err := Open("./testdata/nonexistent", testname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetContext(env)
+	return c
+}
+
+func openCassette(t *testing.T, dir string, env ...string) *Cassette {
+	c, err := Open(dir, "TestVM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetContext(env)
+	return c
+}
+
+func save(t *testing.T, c *Cassette) {
+	err := c.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func send(t *testing.T, sender autorest.Sender, method string, url string) (int, string) {
+	r, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := sender.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "klb-tests-cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
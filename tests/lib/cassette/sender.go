@@ -0,0 +1,136 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// Sender records the requests sent with next, when replaying the
+// responses come from the cassette and next is never called.
+//
+// Polling the same resource may take a different number of requests
+// on each run, so GET requests that were all replayed already get
+// the last response again. Requests are only answered with responses
+// recorded after the last replayed command and before the next one,
+// so the responses match the state left by the commands. Requests
+// not found on the cassette get a 400 (bad request) response, that
+// is a permanent error, so they are not tried again.
+func (c *Cassette) Sender(next autorest.Sender) autorest.Sender {
+	return autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		body, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+		if c.replaying {
+			return c.replayRequest(r, body), nil
+		}
+		return c.recordRequest(next, r, body)
+	})
+}
+
+func (c *Cassette) recordRequest(
+	next autorest.Sender,
+	r *http.Request,
+	body string,
+) (*http.Response, error) {
+	resp, err := next.Do(r)
+	if err != nil {
+		return resp, err
+	}
+	respbody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respbody))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.recording.Interactions = append(c.recording.Interactions, Interaction{
+		Request: &Request{
+			Method:     r.Method,
+			URL:        c.scrubArgs([]string{r.URL.String()})[0],
+			Body:       c.scrubOutput(body),
+			StatusCode: resp.StatusCode,
+			Response:   c.scrubOutput(string(respbody)),
+		},
+	})
+	return resp, nil
+}
+
+func (c *Cassette) replayRequest(r *http.Request, body string) *http.Response {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	url := c.scrubArgs([]string{r.URL.String()})[0]
+	body = c.scrubOutput(body)
+
+	next := len(c.recording.Interactions)
+	for i := c.last + 1; i < len(c.recording.Interactions); i++ {
+		if c.recording.Interactions[i].Command != nil && !c.used[i] {
+			next = i
+			break
+		}
+	}
+
+	repeat := -1
+	for i := 0; i < next; i++ {
+		req := c.recording.Interactions[i].Request
+		if req == nil || req.Method != r.Method || req.URL != url || req.Body != body {
+			continue
+		}
+		if i > c.last && !c.used[i] {
+			c.used[i] = true
+			return c.response(r, req.StatusCode, c.restore(req.Response))
+		}
+		repeat = i
+	}
+	if repeat >= 0 && r.Method == http.MethodGet {
+		req := c.recording.Interactions[repeat].Request
+		return c.response(r, req.StatusCode, c.restore(req.Response))
+	}
+
+	msg := fmt.Sprintf("cassette[%s] has no response for %s %s", c.path, r.Method, url)
+	return c.response(
+		r,
+		http.StatusBadRequest,
+		fmt.Sprintf(`{"error":{"code":"CassetteMiss","message":%q}}`, msg),
+	)
+}
+
+func (c *Cassette) response(r *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type": []string{"application/json; charset=utf-8"},
+		},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// readBody reads the request body, leaving it
+// ready to be read again by the next sender.
+func readBody(r *http.Request) (string, error) {
+	if r.Body == nil {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
@@ -0,0 +1,193 @@
+// Package golden compares the state of Azure resources and the
+// commands invoked by scripts with golden files checked in with the
+// tests, catching regressions on fields nobody thought to assert.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// goldendir is where golden files are saved, relative to the test package
+const goldendir = "./testdata/golden"
+
+var update bool
+
+// RegisterFlags registers the -update flag on the given flag set,
+// it must be called on TestMain before parsing flags.
+func RegisterFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&update, "update", update, "create or update the golden files with the current results")
+}
+
+// strippedFields change on every run, or while
+// Azure works on a resource, so they are never compared.
+var strippedFields = map[string]bool{
+	"id":                true,
+	"etag":              true,
+	"resourceguid":      true,
+	"provisioningstate": true,
+}
+
+// uniqueName matches the unique names generated by the tests,
+// like the ones from fixture.NewUniqueName, keeping the prefix.
+var uniqueName = regexp.MustCompile(`(klb-[A-Za-z0-9_]+)-[0-9]{9,}-[0-9]+`)
+
+var subscription = regexp.MustCompile(`(?i)(/subscriptions/)[0-9a-f-]{36}`)
+
+// Files are the golden files of a test.
+type Files struct {
+	t            *testing.T
+	dir          string
+	replacements []string
+}
+
+// New creates the golden files of the given testname, saved on
+// ./testdata/golden/<testname>. Missing golden files fail the test,
+// to create or update them run the tests with -update.
+func New(t *testing.T, testname string) *Files {
+	return &Files{t: t, dir: filepath.Join(goldendir, testname)}
+}
+
+// Replace replaces the given value by the placeholder before comparing,
+// useful for values that change on every run, like generated names.
+func (g *Files) Replace(value string, placeholder string) {
+	g.replacements = append(g.replacements, value, placeholder)
+}
+
+// AssertResource compares the given resource, like a network.LoadBalancer,
+// with the golden file of the given name. The resource is normalized
+// first, removing IDs, etags, provisioning states and timestamps.
+func (g *Files) AssertResource(name string, resource interface{}) {
+	g.t.Helper()
+	data, err := json.Marshal(resource)
+	if err != nil {
+		g.t.Fatalf("encoding resource %s: %s", name, err)
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		g.t.Fatalf("decoding resource %s: %s", name, err)
+	}
+	g.assert(name+".json", normalize(value))
+}
+
+// AssertCommands compares the command lines of the transcript, like
+// the one of a shell or the calls of a module, with the golden file
+// of the given name. The results of the commands are not compared.
+func (g *Files) AssertCommands(name string, transcript nash.Transcript) {
+	g.t.Helper()
+	cmdlines := [][]string{}
+	for _, invocation := range transcript.Invocations {
+		cmdlines = append(cmdlines, invocation.Args)
+	}
+	g.assert(name+".commands.json", cmdlines)
+}
+
+func (g *Files) assert(filename string, value interface{}) {
+	g.t.Helper()
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	// WHY: keeps URLs on commands readable on the golden files
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(value)
+	if err != nil {
+		g.t.Fatalf("encoding golden file %s: %s", filename, err)
+	}
+	got := g.scrub(data.String())
+
+	path := filepath.Join(g.dir, filename)
+	if update {
+		g.save(path, got)
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		g.t.Fatalf("golden file %s not found, run with -update to create it", path)
+	}
+	if err != nil {
+		g.t.Fatalf("reading golden file %s: %s", path, err)
+	}
+	if string(want) == got {
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(want)),
+		B:        difflib.SplitLines(got),
+		FromFile: path,
+		ToFile:   "got",
+		Context:  3,
+	})
+	if err != nil {
+		g.t.Fatalf("diffing golden file %s: %s", path, err)
+	}
+	g.t.Fatalf("golden file %s differs, run with -update if it is expected:\n%s", path, diff)
+}
+
+func (g *Files) save(path string, data string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		g.t.Fatalf("creating golden files dir: %s", err)
+	}
+	err = ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		g.t.Fatalf("saving golden file %s: %s", path, err)
+	}
+	g.t.Logf("golden file %s saved", path)
+}
+
+// scrub replaces everything that changes between runs
+// by placeholders, redacting secrets too.
+func (g *Files) scrub(s string) string {
+	s = strings.NewReplacer(g.replacements...).Replace(s)
+	s = uniqueName.ReplaceAllString(s, "${1}-{{unique}}")
+	s = subscription.ReplaceAllString(s, "${1}{{subscription}}")
+	return testlog.RedactWith(s, "{{scrubbed}}")
+}
+
+// normalize removes the fields that change between runs
+// from a resource decoded from JSON.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := map[string]interface{}{}
+		for key, field := range v {
+			if strippedFields[strings.ToLower(key)] || isTimestamp(field) {
+				continue
+			}
+			res[key] = normalize(field)
+		}
+		return res
+	case []interface{}:
+		res := []interface{}{}
+		for _, item := range v {
+			res = append(res, normalize(item))
+		}
+		return res
+	}
+	return value
+}
+
+func isTimestamp(value interface{}) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	// WHY: time.Parse accepts fractional seconds even when
+	// the layout has none, like the ones returned by Azure.
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
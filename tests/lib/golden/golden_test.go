@@ -0,0 +1,137 @@
+package golden
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+)
+
+func TestMain(m *testing.M) {
+	RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func TestNormalize(t *testing.T) {
+	type TestCase struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}
+
+	tests := []TestCase{
+		{
+			name: "StrippedFields",
+			value: map[string]interface{}{
+				"id":                "/subscriptions/id",
+				"etag":              `W/"etag"`,
+				"name":              "lb",
+				"resourceGuid":      "guid",
+				"provisioningState": "Succeeded",
+			},
+			want: map[string]interface{}{"name": "lb"},
+		},
+		{
+			name: "Timestamps",
+			value: map[string]interface{}{
+				"timeCreated": "2017-10-17T15:04:05.1234567Z",
+				"date":        "2017-10-17",
+				"port":        8080.0,
+			},
+			want: map[string]interface{}{"date": "2017-10-17", "port": 8080.0},
+		},
+		{
+			name: "Nested",
+			value: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"subnet": map[string]interface{}{"id": "/subscriptions/id"},
+					"rules": []interface{}{
+						map[string]interface{}{"ID": "/subscriptions/id", "name": "rule"},
+						"plain",
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"subnet": map[string]interface{}{},
+					"rules": []interface{}{
+						map[string]interface{}{"name": "rule"},
+						"plain",
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := normalize(test.value)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected[%v], got[%v]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestScrub(t *testing.T) {
+	type TestCase struct {
+		name         string
+		replacements []string
+		value        string
+		want         string
+	}
+
+	tests := []TestCase{
+		{
+			name:  "UniqueName",
+			value: "klb-LoadBalancer-1508231234-4242/lb",
+			want:  "klb-LoadBalancer-{{unique}}/lb",
+		},
+		{
+			name:  "Subscription",
+			value: "/subscriptions/5D4C6F8B-2A5E-4F3C-9B1D-7E8A9C0B1D2E/resourceGroups/group",
+			want:  "/subscriptions/{{subscription}}/resourceGroups/group",
+		},
+		{
+			name:  "Secret",
+			value: "DefaultEndpointsProtocol=https;AccountKey=c2VjcmV0;",
+			want:  "DefaultEndpointsProtocol=https;AccountKey={{scrubbed}};",
+		},
+		{
+			name:         "Replacements",
+			replacements: []string{"10.66.1.150", "{{lbip}}", "eastus2", "{{location}}"},
+			value:        "ip 10.66.1.150 at eastus2",
+			want:         "ip {{lbip}} at {{location}}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := New(t, test.name)
+			for i := 0; i < len(test.replacements); i += 2 {
+				g.Replace(test.replacements[i], test.replacements[i+1])
+			}
+			got := g.scrub(test.value)
+			if got != test.want {
+				t.Errorf("expected[%s], got[%s]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestAssertCommands(t *testing.T) {
+	g := New(t, "TestAssertCommands")
+	g.Replace("eastus2", "{{location}}")
+	g.AssertCommands("vm", nash.Transcript{Invocations: []nash.Invocation{
+		{
+			Args:   []string{"az", "vm", "create", "--name", "klb-VMCreation-1508231234-42", "--location", "eastus2"},
+			Stdout: "results are not compared",
+		},
+		{
+			Args: []string{"az", "vm", "show", "--ids", "/subscriptions/5d4c6f8b-2a5e-4f3c-9b1d-7e8a9c0b1d2e/vm?a=b&c=d"},
+		},
+	}})
+}
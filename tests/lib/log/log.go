@@ -2,30 +2,62 @@ package log
 
 import (
 	"flag"
-	"log"
-    "fmt"
+	"fmt"
+	"io"
 	"os"
-	"strings"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
 type TearDownFunc func()
 
-type loggerBuilder func(t *testing.T, testname string) (*log.Logger, TearDownFunc)
+type sinkBuilder func(t *testing.T, testname string) (*sink, TearDownFunc)
 
-const logsdir = "./testdata/logs"
+var logbuilders = map[string][]sinkBuilder{
+	"file":   {newFile},
+	"stdout": {newStdout},
+	"both":   {newFile, newStdout},
+}
+
+var logger = "file"
 
-var logbuilders map[string]loggerBuilder
+var logformat string
 
-var logger string
+var printLogger sync.Once
 
-//New creates a log.Logger for the given testame.
+//RegisterFlags registers the flags of the test logs on the given
+//flag set. It must be called before parsing flags, on TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		log.RegisterFlags(flag.CommandLine)
+//		flag.Parse()
+//		os.Exit(m.Run())
+//	}
+//
+//Tests of packages that don't register the flags use the defaults.
+func RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&logger, "logger", logger, "test logger, valid values: 'stdout' 'file' 'both'")
+	flags.StringVar(&logformat, "logformat", logformat, "test log format, valid values: 'json' 'console', defaults to json on files and console on stdout")
+	flags.IntVar(&retention, "logretention", retention, "number of runs kept on the logs dir, 0 keeps all of them")
+}
+
+//New creates a Logger for the given testame.
 //This will save the logs on our common logs dir
 //or stdout, according to what is configured by the argument
 //-logger passed to go test using -args.
 //
 //Example stdout: go test ./... -args -logger stdout
 //Example file: go test ./... -args -logger file
+//Example both: go test ./... -args -logger both
+//
+//Each run saves its logs on its own dir, like
+//./testdata/logs/20170102-150405, and the last run
+//can always be found at ./testdata/logs/latest.
+//
+//Each line is a structured entry, with the test name and the fields
+//added with With. Files use JSON lines and stdout uses a human
+//readable format, unless -logformat is json or console.
 //
 //This is not very usual on Go tests but we have pretty
 //long running tests that may take some time to run and
@@ -33,42 +65,76 @@ var logger string
 //on development.
 //
 //You should not use the logger instance after you call TearDownFunc.
-func New(t *testing.T, testname string) (*log.Logger, TearDownFunc) {
-	builder, ok := logbuilders[logger]
+func New(t *testing.T, testname string) (*Logger, TearDownFunc) {
+	printLogger.Do(func() {
+		fmt.Printf("klb integration tests logger: [%s]\n", logger)
+	})
+	builders, ok := logbuilders[logger]
 	if !ok {
 		t.Fatalf("unknow logger: %s", logger)
 	}
-	return builder(t, testname)
+	if logformat != "" && logformat != FormatJSON && logformat != FormatConsole {
+		t.Fatalf("unknow log format: %s", logformat)
+	}
+
+	sinks := []*sink{}
+	teardowns := []TearDownFunc{}
+	for _, builder := range builders {
+		s, teardown := builder(t, testname)
+		sinks = append(sinks, s)
+		teardowns = append(teardowns, teardown)
+	}
+	logger := newStructured(sinks, Fields{Test: t.Name()})
+	return logger, func() {
+		for _, teardown := range teardowns {
+			teardown()
+		}
+	}
+}
+
+func newSink(w io.Writer, defaultFormat string) *sink {
+	format := logformat
+	if format == "" {
+		format = defaultFormat
+	}
+	return &sink{w: w, format: format}
 }
 
-func newFile(t *testing.T, testname string) (*log.Logger, TearDownFunc) {
-	err := os.MkdirAll(logsdir, 0755)
+//Path returns the path of a file for the given testname, with the
+//given suffix, on the logs dir of this run. Useful to save files
+//related to a test along with its logs.
+func Path(t *testing.T, testname string, suffix string) string {
+	dir, err := runDir()
 	if err != nil {
 		t.Fatalf("creating test logs dir: %s:", err)
 	}
-	logspath := strings.Join([]string{logsdir, testname + ".logs"}, "/")
+	return filepath.Join(dir, testname+suffix)
+}
+
+//LogsPath returns the path of the logs file of the given testname,
+//or an empty string if logs are not saved on files.
+func LogsPath(testname string) string {
+	if logger != "file" && logger != "both" {
+		return ""
+	}
+	dir, err := runDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, testname+".logs")
+}
+
+func newFile(t *testing.T, testname string) (*sink, TearDownFunc) {
+	logspath := Path(t, testname, ".logs")
 	file, err := os.Create(logspath)
 	if err != nil {
 		t.Fatalf("error opening log file: %s", err)
 	}
-	logger := log.New(file, "", log.Ltime)
-	return logger, func() {
+	return newSink(file, FormatJSON), func() {
 		file.Close()
 	}
 }
 
-func newStdout(t *testing.T, testname string) (*log.Logger, TearDownFunc) {
-	logger := log.New(os.Stdout, testname, log.Ltime)
-	return logger, func() {}
-}
-
-func init() {
-	flag.StringVar(&logger, "logger", "file", "test logger, valid values: 'stdout' 'file'")
-	flag.Parse()
-
-    fmt.Printf("klb integration tests logger: [%s]\n", logger)
-	logbuilders = map[string]loggerBuilder{
-		"file":   newFile,
-		"stdout": newStdout,
-	}
+func newStdout(t *testing.T, testname string) (*sink, TearDownFunc) {
+	return newSink(os.Stdout, FormatConsole), func() {}
 }
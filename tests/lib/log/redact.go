@@ -0,0 +1,77 @@
+package log
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the secrets on logs.
+const Redacted = "[REDACTED]"
+
+// minSecretSize avoids masking short values that
+// would match too much unrelated text.
+const minSecretSize = 6
+
+// secretPatterns matches secrets that must never reach
+// the logs, the cassettes or the test output. The first
+// group, that identifies the secret, is kept.
+var secretPatterns = []*regexp.Regexp{
+	// storage account keys, like from azure_storage_account_get_keys
+	regexp.MustCompile(`()[A-Za-z0-9+/]{86}==`),
+	regexp.MustCompile(`(AccountKey=)[^;"'\s]+`),
+	// SAS tokens signatures, like from azure_snapshot_grant_access
+	regexp.MustCompile(`(sig=)[^&"'\s]+`),
+	// bearer and JWT tokens
+	regexp.MustCompile(`(?i)(bearer )[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`()eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	// client secrets passed on environment variables and logins
+	regexp.MustCompile(`(?i)([A-Z_]*(?:SECRET|PASSWORD)=)[^\s"']+`),
+	regexp.MustCompile(`(login\s.*(?:-p|--password)[\s=]+)[^\s"']+`),
+}
+
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// AddSecret makes the given values be redacted everywhere, like
+// the client secret of the session. Values shorter than 6
+// characters are ignored, since they would mask too much.
+func AddSecret(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	for _, value := range values {
+		if len(value) >= minSecretSize {
+			secrets.values = append(secrets.values, value)
+		}
+	}
+	// WHY: longer values first, so a secret containing
+	// another one is fully redacted.
+	sort.Slice(secrets.values, func(i, j int) bool {
+		return len(secrets.values[i]) > len(secrets.values[j])
+	})
+}
+
+// Redact replaces known secret values and anything
+// that looks like a secret by Redacted.
+func Redact(s string) string {
+	return RedactWith(s, Redacted)
+}
+
+// RedactWith replaces known secret values and anything
+// that looks like a secret by the given placeholder.
+func RedactWith(s string, placeholder string) string {
+	secrets.RLock()
+	for _, value := range secrets.values {
+		s = strings.Replace(s, value, placeholder, -1)
+	}
+	secrets.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+placeholder)
+	}
+	return s
+}
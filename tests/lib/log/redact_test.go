@@ -0,0 +1,86 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	AddSecret("klb-tests-client-secret", "klb-tests-client-secret-longer", "short")
+
+	type TestCase struct {
+		name string
+		in   string
+		want string
+	}
+
+	key := strings.Repeat("a", 86) + "=="
+	tests := []TestCase{
+		{
+			name: "KnownSecret",
+			in:   "login with klb-tests-client-secret done",
+			want: "login with [REDACTED] done",
+		},
+		{
+			name: "LongerSecretFirst",
+			in:   "klb-tests-client-secret-longer",
+			want: "[REDACTED]",
+		},
+		{
+			name: "ShortValuesIgnored",
+			in:   "short",
+			want: "short",
+		},
+		{
+			name: "StorageKey",
+			in:   "key: " + key,
+			want: "key: [REDACTED]",
+		},
+		{
+			name: "ConnectionString",
+			in:   "AccountName=klb;AccountKey=abc/def+g==;EndpointSuffix=core",
+			want: "AccountName=klb;AccountKey=[REDACTED];EndpointSuffix=core",
+		},
+		{
+			name: "SASSignature",
+			in:   "https://klb.blob.core.windows.net/c/b?se=2017&sig=abc%2Fdef&sp=r",
+			want: "https://klb.blob.core.windows.net/c/b?se=2017&sig=[REDACTED]&sp=r",
+		},
+		{
+			name: "Bearer",
+			in:   "Authorization: Bearer abc.def-ghi",
+			want: "Authorization: Bearer [REDACTED]",
+		},
+		{
+			name: "EnvSecret",
+			in:   "AZURE_CLIENT_SECRET=hunter22 AZURE_TENANT_ID=tenant",
+			want: "AZURE_CLIENT_SECRET=[REDACTED] AZURE_TENANT_ID=tenant",
+		},
+		{
+			name: "LoginPassword",
+			in:   "azure login -u user -p hunter22 --tenant t",
+			want: "azure login -u user -p [REDACTED] --tenant t",
+		},
+		{
+			name: "NoSecrets",
+			in:   "creating vm klb-vm",
+			want: "creating vm klb-vm",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Redact(test.in)
+			if got != test.want {
+				t.Errorf("expected[%s], got[%s]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRedactWith(t *testing.T) {
+	got := RedactWith("AccountKey=abc;", "{{scrubbed}}")
+	if got != "AccountKey={{scrubbed}};" {
+		t.Errorf("unexpected redaction: %s", got)
+	}
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const logsdir = "./testdata/logs"
+
+// LatestRun is the link to the logs dir of the last run.
+const LatestRun = "latest"
+
+// runDirFormat names the logs dir of each run by its start time.
+const runDirFormat = "20060102-150405"
+
+// retention is how many runs are kept on the logs dir.
+var retention = 10
+
+var run struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// runDir returns the logs dir of this run, creating it on first use.
+func runDir() (string, error) {
+	run.once.Do(func() {
+		run.dir, run.err = newRunDir(logsdir, time.Now(), retention)
+	})
+	return run.dir, run.err
+}
+
+// RunID identifies this test run, it is the name of the
+// dir with the logs of the run, like 20170102-150405.
+func RunID() string {
+	dir, err := runDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(dir)
+}
+
+// newRunDir creates the logs dir of a run started at the given
+// time, points the latest link to it and removes old runs.
+func newRunDir(root string, start time.Time, keep int) (string, error) {
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	name := start.Format(runDirFormat)
+	dir := filepath.Join(root, name)
+	for i := 2; ; i++ {
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		// WHY: runs started on the same second
+		dir = filepath.Join(root, fmt.Sprintf("%s.%d", name, i))
+	}
+
+	err = linkLatest(root, filepath.Base(dir))
+	if err != nil {
+		return "", fmt.Errorf("linking latest run: %s", err)
+	}
+	err = pruneRuns(root, keep)
+	if err != nil {
+		return "", fmt.Errorf("removing old runs: %s", err)
+	}
+	return dir, nil
+}
+
+func linkLatest(root string, name string) error {
+	// WHY: renaming is atomic, so klb-progress never
+	// sees the latest link missing.
+	tmp := filepath.Join(root, "."+LatestRun)
+	os.Remove(tmp)
+	err := os.Symlink(name, tmp)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(root, LatestRun))
+}
+
+// pruneRuns removes the oldest run dirs, keeping the last keep
+// ones. Other files on the logs dir are left untouched.
+func pruneRuns(root string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	runs := []string{}
+	for _, file := range files {
+		if file.IsDir() && isRunDir(file.Name()) {
+			runs = append(runs, file.Name())
+		}
+	}
+	if len(runs) <= keep {
+		return nil
+	}
+	sort.Strings(runs)
+	for _, name := range runs[:len(runs)-keep] {
+		err := os.RemoveAll(filepath.Join(root, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isRunDir(name string) bool {
+	if len(name) < len(runDirFormat) {
+		return false
+	}
+	_, err := time.Parse(runDirFormat, name[:len(runDirFormat)])
+	return err == nil
+}
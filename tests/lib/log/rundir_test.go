@@ -0,0 +1,132 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPruneRuns(t *testing.T) {
+	type TestCase struct {
+		name  string
+		dirs  []string
+		files []string
+		keep  int
+		want  []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "KeepAll",
+			dirs: []string{"20170101-100000", "20170102-100000"},
+			keep: 0,
+			want: []string{"20170101-100000", "20170102-100000"},
+		},
+		{
+			name: "UnderRetention",
+			dirs: []string{"20170101-100000", "20170102-100000"},
+			keep: 2,
+			want: []string{"20170101-100000", "20170102-100000"},
+		},
+		{
+			name: "OldestRemoved",
+			dirs: []string{"20170103-100000", "20170101-100000", "20170102-100000"},
+			keep: 2,
+			want: []string{"20170102-100000", "20170103-100000"},
+		},
+		{
+			name: "SameSecond",
+			dirs: []string{"20170101-100000", "20170101-100000.2", "20170102-100000"},
+			keep: 2,
+			want: []string{"20170101-100000.2", "20170102-100000"},
+		},
+		{
+			name:  "OtherFilesUntouched",
+			dirs:  []string{"20170101-100000", "20170102-100000", "cassettes", "2017"},
+			files: []string{"20170100-100000", "TestVM.logs"},
+			keep:  1,
+			want:  []string{"2017", "20170100-100000", "20170102-100000", "TestVM.logs", "cassettes"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "klb-tests-rundir")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+
+			for _, dir := range test.dirs {
+				err := os.Mkdir(filepath.Join(root, dir), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, file := range test.files {
+				err := ioutil.WriteFile(filepath.Join(root, file), nil, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = pruneRuns(root, test.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := listDir(t, root)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected[%q], got[%q]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestNewRunDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "klb-tests-rundir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	start := time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC)
+	for _, want := range []string{"20170102-150405", "20170102-150405.2", "20170102-150405.3"} {
+		dir, err := newRunDir(root, start, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(dir) != want {
+			t.Errorf("expected run dir[%s], got[%s]", want, dir)
+		}
+		latest, err := os.Readlink(filepath.Join(root, LatestRun))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest != want {
+			t.Errorf("expected latest link to[%s], got[%s]", want, latest)
+		}
+	}
+
+	want := []string{"20170102-150405.2", "20170102-150405.3", LatestRun}
+	got := listDir(t, root)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected[%q], got[%q]", want, got)
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,179 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phases of a test, used on the phase field.
+const (
+	PhaseSetup    = "fixture-setup"
+	PhaseTest     = "test"
+	PhaseScript   = "script"
+	PhaseAssert   = "assert"
+	PhaseTeardown = "teardown"
+)
+
+// Fields are the contextual fields of each log entry,
+// empty fields are omitted.
+type Fields struct {
+	Test     string `json:"test,omitempty"`
+	ResGroup string `json:"resgroup,omitempty"`
+	Op       string `json:"op,omitempty"`
+	Attempt  int    `json:"attempt,omitempty"`
+	Script   string `json:"script,omitempty"`
+	Phase    string `json:"phase,omitempty"`
+}
+
+// merge returns the fields with the non empty fields of other.
+func (f Fields) merge(other Fields) Fields {
+	if other.Test != "" {
+		f.Test = other.Test
+	}
+	if other.ResGroup != "" {
+		f.ResGroup = other.ResGroup
+	}
+	if other.Op != "" {
+		f.Op = other.Op
+	}
+	if other.Attempt != 0 {
+		f.Attempt = other.Attempt
+	}
+	if other.Script != "" {
+		f.Script = other.Script
+	}
+	if other.Phase != "" {
+		f.Phase = other.Phase
+	}
+	return f
+}
+
+// Entry is a log entry, as saved on the JSON format.
+type Entry struct {
+	Time time.Time `json:"time"`
+	Fields
+	Msg string `json:"msg"`
+}
+
+// Log formats.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// sink writes the entries of all loggers of a test.
+type sink struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	format string
+}
+
+func (s *sink) write(fields Fields, msg string) error {
+	entry := Entry{Time: time.Now(), Fields: fields, Msg: Redact(msg)}
+	var line []byte
+	if s.format == FormatJSON {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(formatConsole(entry))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+// formatConsole formats the entry to be read by humans, like:
+// 15:04:05 TestVM/Creation [test] VM.AssertExists:vm#2: msg
+func formatConsole(entry Entry) string {
+	context := []string{}
+	if entry.Test != "" {
+		context = append(context, entry.Test)
+	}
+	if entry.Phase != "" {
+		context = append(context, "["+entry.Phase+"]")
+	}
+	if entry.Script != "" {
+		context = append(context, entry.Script)
+	}
+	if entry.Op != "" {
+		op := entry.Op
+		if entry.Attempt != 0 {
+			op = fmt.Sprintf("%s#%d", op, entry.Attempt)
+		}
+		context = append(context, op)
+	}
+	if len(context) == 0 {
+		return fmt.Sprintf("%s %s\n", entry.Time.Format("15:04:05"), entry.Msg)
+	}
+	return fmt.Sprintf(
+		"%s %s: %s\n",
+		entry.Time.Format("15:04:05"),
+		strings.Join(context, " "),
+		entry.Msg,
+	)
+}
+
+// entryWriter writes each log line as an entry with
+// its fields to all sinks of a test.
+type entryWriter struct {
+	sinks  []*sink
+	fields Fields
+}
+
+func (e *entryWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	for _, s := range e.sinks {
+		err := s.write(e.fields, msg)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Logger is a log.Logger that writes structured entries,
+// carrying the fields added to it with With.
+type Logger struct {
+	*log.Logger
+	w *entryWriter
+}
+
+func newStructured(sinks []*sink, fields Fields) *Logger {
+	w := &entryWriter{sinks: sinks, fields: fields}
+	return &Logger{Logger: log.New(w, "", 0), w: w}
+}
+
+// Wrap creates a Logger that writes to the given log.Logger, like
+// the ones of Go tools. Entries are written on the console format,
+// so the fields added with With are not lost.
+func Wrap(logger *log.Logger) *Logger {
+	return newStructured([]*sink{{w: printer{logger}, format: FormatConsole}}, Fields{})
+}
+
+// printer writes each line to a log.Logger.
+type printer struct {
+	logger *log.Logger
+}
+
+func (p printer) Write(line []byte) (int, error) {
+	p.logger.Print(string(line))
+	return len(line), nil
+}
+
+// With returns a logger with the given fields added to the
+// fields of the logger, writing to the same place.
+//
+// Example: logger.With(log.Fields{Op: "VM.Create", Attempt: 2})
+func (l *Logger) With(fields Fields) *Logger {
+	return newStructured(l.w.sinks, l.w.fields.merge(fields))
+}
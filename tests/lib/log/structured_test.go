@@ -0,0 +1,105 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerWith(t *testing.T) {
+	var output bytes.Buffer
+	logger := newStructured([]*sink{{w: &output, format: FormatJSON}}, Fields{Test: "TestVM"})
+
+	op := logger.With(Fields{Op: "VM.Create", Phase: PhaseTest})
+	op.With(Fields{Attempt: 2}).Println("attempt")
+	op.Println("op")
+	logger.Println("test")
+
+	want := []Fields{
+		{Test: "TestVM", Op: "VM.Create", Attempt: 2, Phase: PhaseTest},
+		{Test: "TestVM", Op: "VM.Create", Phase: PhaseTest},
+		{Test: "TestVM"},
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d entries, got:\n%s", len(want), output.String())
+	}
+	for i, line := range lines {
+		var entry Entry
+		err := json.Unmarshal([]byte(line), &entry)
+		if err != nil {
+			t.Fatalf("invalid entry[%s]: %s", line, err)
+		}
+		if entry.Fields != want[i] {
+			t.Errorf("entry[%d]: expected fields[%+v], got[%+v]", i, want[i], entry.Fields)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	var output bytes.Buffer
+	logger := Wrap(log.New(&output, "", 0))
+
+	logger.Println("plain")
+	logger.With(Fields{Op: "Plan", Attempt: 3}).Println("with fields")
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got:\n%s", output.String())
+	}
+	if !strings.HasSuffix(lines[0], " plain") || strings.Contains(lines[0], ":  ") {
+		t.Errorf("unexpected plain line: %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], " Plan#3: with fields") {
+		t.Errorf("fields lost on line: %q", lines[1])
+	}
+}
+
+func TestFormatConsole(t *testing.T) {
+	type TestCase struct {
+		name  string
+		entry Entry
+		want  string
+	}
+
+	at := time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []TestCase{
+		{
+			name:  "NoFields",
+			entry: Entry{Time: at, Msg: "msg"},
+			want:  "15:04:05 msg\n",
+		},
+		{
+			name:  "Test",
+			entry: Entry{Time: at, Fields: Fields{Test: "TestVM"}, Msg: "msg"},
+			want:  "15:04:05 TestVM: msg\n",
+		},
+		{
+			name: "AllFields",
+			entry: Entry{
+				Time: at,
+				Fields: Fields{
+					Test:    "TestVM/Creation",
+					Phase:   PhaseAssert,
+					Script:  "vm.sh",
+					Op:      "VM.AssertExists:vm",
+					Attempt: 2,
+				},
+				Msg: "msg",
+			},
+			want: "15:04:05 TestVM/Creation [assert] vm.sh VM.AssertExists:vm#2: msg\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := formatConsole(test.entry)
+			if got != test.want {
+				t.Errorf("expected[%q], got[%q]", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package nash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/retrier"
+)
+
+// resultMarker is written before the values of each result,
+// allowing us to tell an empty list from an empty string.
+const resultMarker = "klb-tests-result"
+
+// itemMarker is written before the values of each item
+// of results that are lists of lists.
+const itemMarker = "klb-tests-item"
+
+// call is a call of a klb function.
+type call struct {
+	module  string
+	fn      string
+	args    []interface{}
+	results int
+	// nested results are lists of lists
+	nested bool
+}
+
+// Call imports the given klb module, like "azure/vm", and calls
+// the function fn with the given args, aborting the test if it
+// never succeeds. Args can be strings or lists of strings ([]string).
+//
+// Just like the scripts on testdata, azure_login is called before
+// calling the function, unless the shell was created by NewOffline.
+// Calls run on a nash process, or on the worker when it is enabled,
+// and will be tried again until success or until the shell context
+// gets cancelled.
+func (s *Shell) Call(module string, fn string, args ...interface{}) {
+	s.CallN(module, fn, 0, args...)
+}
+
+// CallString calls the function fn, that returns a string.
+func (s *Shell) CallString(module string, fn string, args ...interface{}) string {
+	res := s.CallN(module, fn, 1, args...)[0]
+	if len(res) != 1 {
+		s.t.Fatalf("%s: expected string result, got[%q]", fn, res)
+	}
+	return res[0]
+}
+
+// CallStrings calls the function fn, that returns a list of strings.
+func (s *Shell) CallStrings(module string, fn string, args ...interface{}) []string {
+	return s.CallN(module, fn, 1, args...)[0]
+}
+
+// CallLists calls the function fn, that returns a list of lists
+// of strings, like azure_vm_get_datadisks_ids_lun.
+func (s *Shell) CallLists(module string, fn string, args ...interface{}) [][]string {
+	var res [][]string
+	s.retry(module, fn, func(ctx context.Context) error {
+		values, err := s.callOnce(ctx, call{
+			module:  module,
+			fn:      fn,
+			args:    args,
+			results: 1,
+			nested:  true,
+		})
+		if err != nil {
+			return err
+		}
+		res, err = parseItems(values[0])
+		return err
+	})
+	return res
+}
+
+// CallN calls the function fn, that returns the given number of
+// results. Results are returned as lists, strings are returned
+// as lists with a single element.
+func (s *Shell) CallN(module string, fn string, results int, args ...interface{}) [][]string {
+	var res [][]string
+	s.retry(module, fn, func(ctx context.Context) error {
+		var err error
+		res, err = s.callOnce(ctx, call{
+			module:  module,
+			fn:      fn,
+			args:    args,
+			results: results,
+		})
+		return err
+	})
+	return res
+}
+
+// CallE calls the function fn, that returns the given number of
+// results followed by an error message, like:
+//
+//	id, err <= azure_lb_addresspool_get_id(...)
+//
+// A non empty error message fails the call, so it is tried again.
+// The results are returned just like on CallN, without the message.
+func (s *Shell) CallE(module string, fn string, results int, args ...interface{}) [][]string {
+	var res [][]string
+	s.retry(module, fn, func(ctx context.Context) error {
+		values, err := s.callOnce(ctx, call{
+			module:  module,
+			fn:      fn,
+			args:    args,
+			results: results + 1,
+		})
+		if err != nil {
+			return err
+		}
+		msg := strings.Join(values[results], " ")
+		if msg != "" {
+			return fmt.Errorf("%s: %s", fn, msg)
+		}
+		res = values[:results]
+		return nil
+	})
+	return res
+}
+
+// CallOnce calls the function fn once like CallN, returning an
+// error if the function fails, like when it calls exit.
+func (s *Shell) CallOnce(module string, fn string, results int, args ...interface{}) ([][]string, error) {
+	return s.callOnce(s.ctx, call{
+		module:  module,
+		fn:      fn,
+		args:    args,
+		results: results,
+	})
+}
+
+func (s *Shell) retry(module string, fn string, work retrier.ContextWorkFunc) {
+	s.retrier.RunContext("Shell.Call:"+module+":"+fn, work)
+}
+
+// callOnce calls the function bound to the given context,
+// killing it when the context is cancelled.
+func (s *Shell) callOnce(ctx context.Context, c call) ([][]string, error) {
+	c.module = strings.TrimSuffix(c.module, ".sh")
+	if !strings.HasPrefix(c.module, "klb/") {
+		c.module = "klb/" + c.module
+	}
+	for i, arg := range c.args {
+		_, err := nashValue(arg)
+		if err != nil {
+			s.t.Fatalf("%s: arg[%d]: %s", c.fn, i, err)
+		}
+	}
+
+	name := c.module + ":" + c.fn
+	s = s.logging(testlog.Fields{Script: name})
+	s.logger.Printf("calling: %s on %s", c.fn, c.module)
+	err := s.rateLimit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
+	var res [][]string
+	if s.worker != nil && !s.shimmed() {
+		res, err = s.worker.call(ctx, s.logger, c)
+	} else {
+		res, err = s.forkCall(ctx, c)
+	}
+	s.logger.Printf("%s result: %q error: %+v", c.fn, res, err)
+	recordScript(s.t, name, start, err)
+	return res, err
+}
+
+// forkCall calls the function on a new nash process.
+func (s *Shell) forkCall(ctx context.Context, c call) ([][]string, error) {
+	var res [][]string
+	err := s.isolated(func(env []string, homedir string, nashpath string) error {
+		scriptpath := filepath.Join(homedir, "klb-tests-call.sh")
+		code := callScript(c, !s.offline, homedir)
+		err := ioutil.WriteFile(scriptpath, []byte(code), 0755)
+		if err != nil {
+			return err
+		}
+		if s.shimmed() {
+			err = s.runShimmed(ctx, env, homedir, scriptpath)
+		} else {
+			err = s.exec(ctx, env, scriptpath)
+		}
+		if err != nil {
+			return err
+		}
+		res, err = readResults(homedir, c.results)
+		return err
+	})
+	return res, err
+}
+
+// callScript generates a nash script that calls the function,
+// writing its results on files at resultsdir. Args must be valid.
+func callScript(c call, login bool, resultsdir string) string {
+	var code bytes.Buffer
+	code.WriteString("#!/usr/bin/env nash\n\n")
+	if login {
+		code.WriteString("import klb/azure/login\n")
+	}
+	fmt.Fprintf(&code, "import %s\n\n", c.module)
+	if login {
+		code.WriteString("azure_login()\n\n")
+	}
+
+	for i, arg := range c.args {
+		value, _ := nashValue(arg)
+		fmt.Fprintf(&code, "klbtestsarg%d = %s\n", i, value)
+	}
+	code.WriteString(callStatements(c, resultsdir))
+	return code.String()
+}
+
+// callStatements generates the nash statements calling the function
+// with the args on the variables klbtestsarg0, klbtestsarg1..., writing
+// its results on files at resultsdir.
+func callStatements(c call, resultsdir string) string {
+	var code bytes.Buffer
+	argnames := []string{}
+	for i := range c.args {
+		argnames = append(argnames, fmt.Sprintf("$klbtestsarg%d", i))
+	}
+
+	fncall := fmt.Sprintf("%s(%s)", c.fn, strings.Join(argnames, ", "))
+	if c.results == 0 {
+		fmt.Fprintf(&code, "%s\n", fncall)
+		return code.String()
+	}
+
+	resnames := []string{}
+	for i := 0; i < c.results; i++ {
+		resnames = append(resnames, fmt.Sprintf("klbtestsres%d", i))
+	}
+	fmt.Fprintf(&code, "%s <= %s\n", strings.Join(resnames, ", "), fncall)
+	for i, name := range resnames {
+		if c.nested {
+			// WHY: printf would flatten the items
+			fmt.Fprintf(&code, "klbtestsflat%d = ()\n", i)
+			fmt.Fprintf(&code, "for klbtestsitem in $%s {\n", name)
+			fmt.Fprintf(&code, "\tklbtestsflat%d <= append($klbtestsflat%d, %q)\n", i, i, itemMarker)
+			fmt.Fprintf(&code, "\tfor klbtestsvalue in $klbtestsitem {\n")
+			fmt.Fprintf(&code, "\t\tklbtestsflat%d <= append($klbtestsflat%d, $klbtestsvalue)\n", i, i)
+			fmt.Fprintf(&code, "\t}\n}\n")
+			name = fmt.Sprintf("klbtestsflat%d", i)
+		}
+		fmt.Fprintf(
+			&code,
+			"printf \"%%s\\\\0\" %q $%s > %s\n",
+			resultMarker, name, nashQuote(filepath.Join(resultsdir, fmt.Sprintf("result%d", i))),
+		)
+	}
+	return code.String()
+}
+
+func readResults(resultsdir string, results int) ([][]string, error) {
+	res := [][]string{}
+	for i := 0; i < results; i++ {
+		path := filepath.Join(resultsdir, fmt.Sprintf("result%d", i))
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading result[%d]: %s", i, err)
+		}
+		values, err := parseResult(i, data)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, values)
+	}
+	return res, nil
+}
+
+func parseResult(i int, data []byte) ([]string, error) {
+	values := readArgs(string(data))
+	if values[0] != resultMarker {
+		return nil, fmt.Errorf("invalid result[%d]: %q", i, data)
+	}
+	return values[1:], nil
+}
+
+// parseItems parses the values of a result that is a list of lists.
+func parseItems(values []string) ([][]string, error) {
+	items := [][]string{}
+	for _, value := range values {
+		if value == itemMarker {
+			items = append(items, []string{})
+			continue
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("invalid list of lists: %q", values)
+		}
+		items[len(items)-1] = append(items[len(items)-1], value)
+	}
+	return items, nil
+}
+
+func nashValue(arg interface{}) (string, error) {
+	switch val := arg.(type) {
+	case string:
+		return nashQuote(val), nil
+	case []string:
+		quoted := []string{}
+		for _, s := range val {
+			quoted = append(quoted, nashQuote(s))
+		}
+		return "(" + strings.Join(quoted, " ") + ")", nil
+	}
+	return "", fmt.Errorf("unsupported type %T, use string or []string", arg)
+}
+
+func nashQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	s = strings.Replace(s, "\t", `\t`, -1)
+	return `"` + s + `"`
+}
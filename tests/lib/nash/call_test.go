@@ -0,0 +1,121 @@
+package nash
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallScript(t *testing.T) {
+	type TestCase struct {
+		name  string
+		call  call
+		login bool
+		want  string
+	}
+
+	tests := []TestCase{
+		{
+			name:  "NoResults",
+			call:  call{module: "klb/azure/vm", fn: "azure_vm_create", args: []interface{}{[]string{"--name", "vm"}}},
+			login: true,
+			want: `#!/usr/bin/env nash
+
+import klb/azure/login
+import klb/azure/vm
+
+azure_login()
+
+klbtestsarg0 = ("--name" "vm")
+azure_vm_create($klbtestsarg0)
+`,
+		},
+		{
+			name: "Results",
+			call: call{module: "klb/azure/lb", fn: "azure_lb_addresspool_get_id", args: []interface{}{"pool", `"lb"`}, results: 2},
+			want: `#!/usr/bin/env nash
+
+import klb/azure/lb
+
+klbtestsarg0 = "pool"
+klbtestsarg1 = "\"lb\""
+klbtestsres0, klbtestsres1 <= azure_lb_addresspool_get_id($klbtestsarg0, $klbtestsarg1)
+printf "%s\\0" "klb-tests-result" $klbtestsres0 > "/results/result0"
+printf "%s\\0" "klb-tests-result" $klbtestsres1 > "/results/result1"
+`,
+		},
+		{
+			name: "Nested",
+			call: call{module: "klb/azure/vm", fn: "azure_vm_get_datadisks_ids_lun", results: 1, nested: true},
+			want: `#!/usr/bin/env nash
+
+import klb/azure/vm
+
+klbtestsres0 <= azure_vm_get_datadisks_ids_lun()
+klbtestsflat0 = ()
+for klbtestsitem in $klbtestsres0 {
+	klbtestsflat0 <= append($klbtestsflat0, "klb-tests-item")
+	for klbtestsvalue in $klbtestsitem {
+		klbtestsflat0 <= append($klbtestsflat0, $klbtestsvalue)
+	}
+}
+printf "%s\\0" "klb-tests-result" $klbtestsflat0 > "/results/result0"
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := callScript(test.call, test.login, "/results")
+			if got != test.want {
+				t.Errorf("expected script:\n%s\ngot:\n%s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	type TestCase struct {
+		name string
+		data string
+		want []string
+	}
+
+	tests := []TestCase{
+		{name: "EmptyString", data: "klb-tests-result\x00\x00", want: []string{""}},
+		{name: "EmptyList", data: "klb-tests-result\x00", want: []string{}},
+		{name: "List", data: "klb-tests-result\x00a b\x00c\x00", want: []string{"a b", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseResult(0, []byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	_, err := parseResult(0, []byte("garbage\x00"))
+	if err == nil {
+		t.Error("expected error parsing result without marker")
+	}
+}
+
+func TestParseItems(t *testing.T) {
+	got, err := parseItems([]string{itemMarker, "disk0", "0", itemMarker, itemMarker, "disk3", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"disk0", "0"}, {}, {"disk3", "3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	_, err = parseItems([]string{"value"})
+	if err == nil {
+		t.Error("expected error parsing values without item")
+	}
+}
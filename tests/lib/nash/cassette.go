@@ -0,0 +1,59 @@
+package nash
+
+import (
+	"github.com/NeowayLabs/klb/tests/lib/cassette"
+)
+
+// UseCassette records all az and azure commands invoked by scripts
+// and calls on the given cassette, or answers them from it when the
+// cassette is replaying, without touching the network. The cassette
+// is saved by whoever opened it.
+//
+// When replaying, any command not found on the cassette fails the
+// script or call and the shell does not try again, since there is no
+// reason to expect a different result. Calls are recorded too, they
+// don't run on the worker while a cassette is used.
+func (s *Shell) UseCassette(c *cassette.Cassette) {
+	s.cassette = c
+	if c.Replaying() {
+		s.logger.Printf("replaying cassette %s", c.Path())
+		return
+	}
+	s.logger.Printf("recording cassette %s", c.Path())
+}
+
+// cassetteStubs returns the shell stubs with the cassette
+// commands replayed from the cassette.
+func (s *Shell) cassetteStubs() map[string]Stub {
+	replay := func(args []string) (Interaction, bool) {
+		cmd, ok := s.cassette.Replay(args)
+		return Interaction{
+			Args:     cmd.Args,
+			Stdout:   cmd.Stdout,
+			Stderr:   cmd.Stderr,
+			ExitCode: cmd.ExitCode,
+		}, ok
+	}
+
+	res := map[string]Stub{}
+	for name, stub := range s.stubs {
+		res[name] = stub
+	}
+	for _, name := range cassette.Commands {
+		res[name] = replay
+	}
+	return res
+}
+
+func (s *Shell) recordCassette(invocations []Invocation) {
+	commands := []cassette.Command{}
+	for _, invocation := range invocations {
+		commands = append(commands, cassette.Command{
+			Args:     invocation.Args,
+			Stdout:   invocation.Stdout,
+			Stderr:   invocation.Stderr,
+			ExitCode: invocation.ExitCode,
+		})
+	}
+	s.cassette.Record(commands)
+}
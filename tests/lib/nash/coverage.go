@@ -0,0 +1,260 @@
+package nash
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// coveredSources are the globs, relative to the klb root dir,
+// of the modules on the coverage report.
+var coveredSources = []string{"azure/*.sh", "azure/blob/fs.sh", "aws/*.sh"}
+
+// fnDecl matches top level function declarations,
+// nested functions are not covered.
+var fnDecl = regexp.MustCompile(`^fn\s+([A-Za-z0-9_]+)\s*\(`)
+
+// coverShim records a function call, it never fails since
+// a failed command aborts the calling script.
+const coverShim = `#!/bin/sh
+# klb tests: records the call of a klb function
+if [ -n "$KLB_TESTS_COVERAGE" ]; then
+	printf '%s\t%s\t%s\n' "$KLB_TESTS_TEST" "$1" "$2" >> "$KLB_TESTS_COVERAGE"
+fi
+exit 0
+`
+
+var coverage struct {
+	enabled bool
+	records string
+}
+
+// EnableCoverage makes all shells record the klb functions called
+// by scripts, so a report can be saved with SaveCoverageReport.
+// It must be called before any test runs, like on TestMain.
+//
+// Coverage is recorded by installing klb with a call to a command
+// on the start of each function, so line numbers on nash errors
+// don't match the klb sources.
+func EnableCoverage() error {
+	records, err := ioutil.TempFile("", "klb-tests-coverage")
+	if err != nil {
+		return err
+	}
+	coverage.enabled = true
+	coverage.records = records.Name()
+	return records.Close()
+}
+
+// coverageEnv returns the environment that records the
+// function calls made by the given test.
+func coverageEnv(testname string) []string {
+	if !coverage.enabled {
+		return nil
+	}
+	return []string{
+		"KLB_TESTS_COVERAGE=" + coverage.records,
+		"KLB_TESTS_TEST=" + testname,
+	}
+}
+
+// CoverageSummary has the totals of a coverage report.
+type CoverageSummary struct {
+	Public  int
+	Covered int
+}
+
+func (c CoverageSummary) String() string {
+	percent := 0.0
+	if c.Public > 0 {
+		percent = 100 * float64(c.Covered) / float64(c.Public)
+	}
+	return fmt.Sprintf(
+		"%d of %d public klb functions covered (%.1f%%)",
+		c.Covered,
+		c.Public,
+		percent,
+	)
+}
+
+// SaveCoverageReport saves a report with all functions declared
+// by klb, how many times they have been called and by which tests.
+// Public functions never called are highlighted as UNCOVERED.
+func SaveCoverageReport(path string) (CoverageSummary, error) {
+	var summary CoverageSummary
+
+	root, err := klbroot()
+	if err != nil {
+		return summary, err
+	}
+	modules, err := declaredFunctions(root)
+	if err != nil {
+		return summary, err
+	}
+	calls, err := readCoverage(coverage.records)
+	if err != nil {
+		return summary, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return summary, err
+	}
+	defer file.Close()
+
+	w := tabwriter.NewWriter(file, 0, 8, 2, ' ', 0)
+	for _, module := range sortedModules(modules) {
+		fns := modules[module]
+		var modsummary CoverageSummary
+		for _, fn := range fns {
+			if isPublic(fn) {
+				modsummary.Public++
+				if len(calls[module][fn]) > 0 {
+					modsummary.Covered++
+				}
+			}
+		}
+		summary.Public += modsummary.Public
+		summary.Covered += modsummary.Covered
+
+		fmt.Fprintf(w, "%s: %s\n", module, modsummary)
+		fmt.Fprintf(w, "\tcalls\tfunction\ttests\n")
+		for _, fn := range fns {
+			tests := calls[module][fn]
+			count := 0
+			testcalls := []string{}
+			for _, test := range sortedTests(tests) {
+				count += tests[test]
+				testcalls = append(testcalls, fmt.Sprintf("%s(%d)", test, tests[test]))
+			}
+			if count == 0 && isPublic(fn) {
+				testcalls = []string{"UNCOVERED"}
+			}
+			fmt.Fprintf(w, "\t%d\t%s\t%s\n", count, fn, strings.Join(testcalls, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "total: %s\n", summary)
+	err = w.Flush()
+	if err != nil {
+		return summary, err
+	}
+	return summary, os.Remove(coverage.records)
+}
+
+// declaredFunctions returns the top level functions
+// of each covered module, in declaration order.
+func declaredFunctions(root string) (map[string][]string, error) {
+	modules := map[string][]string{}
+	for _, pattern := range coveredSources {
+		paths, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			module, _ := filepath.Rel(root, path)
+			modules[module] = []string{}
+			for _, line := range strings.Split(string(data), "\n") {
+				if match := fnDecl.FindStringSubmatch(line); match != nil {
+					modules[module] = append(modules[module], match[1])
+				}
+			}
+		}
+	}
+	return modules, nil
+}
+
+// readCoverage returns the calls count of each
+// test indexed by module and function.
+func readCoverage(records string) (map[string]map[string]map[string]int, error) {
+	file, err := os.Open(records)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	calls := map[string]map[string]map[string]int{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		test, module, fn := fields[0], fields[1], fields[2]
+		if calls[module] == nil {
+			calls[module] = map[string]map[string]int{}
+		}
+		if calls[module][fn] == nil {
+			calls[module][fn] = map[string]int{}
+		}
+		calls[module][fn][test]++
+	}
+	return calls, scanner.Err()
+}
+
+// instrument adds a call to the cover shim at the start of all
+// top level functions of the klb modules installed at libdir.
+func instrument(libdir string, covershim string) error {
+	return filepath.Walk(libdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".sh") {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		module, _ := filepath.Rel(libdir, path)
+
+		lines := strings.Split(string(data), "\n")
+		instrumented := []string{}
+		fn := ""
+		for _, line := range lines {
+			instrumented = append(instrumented, line)
+			if match := fnDecl.FindStringSubmatch(line); match != nil {
+				fn = match[1]
+			}
+			// WHY: the signature may span many lines, the body
+			// starts on the first line ending with a brace.
+			if fn != "" && strings.HasSuffix(strings.TrimSpace(line), "{") {
+				instrumented = append(
+					instrumented,
+					fmt.Sprintf("\t%s %q %q", covershim, module, fn),
+				)
+				fn = ""
+			}
+		}
+		return ioutil.WriteFile(path, []byte(strings.Join(instrumented, "\n")), info.Mode())
+	})
+}
+
+func isPublic(fn string) bool {
+	return !strings.HasPrefix(fn, "_")
+}
+
+func sortedModules(modules map[string][]string) []string {
+	names := []string{}
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedTests(tests map[string]int) []string {
+	names := []string{}
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
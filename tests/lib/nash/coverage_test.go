@@ -0,0 +1,224 @@
+package nash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const coverModule = `# klb like module
+fn azure_thing_create(name, location) {
+	azure thing create $name $location
+}
+
+fn azure_thing_update(
+	name,
+	size,
+) {
+	fn nested() {
+		echo "nested functions are not covered"
+	}
+	nested()
+}
+
+fn _azure_thing_private() {
+	return ""
+}
+`
+
+func TestDeclaredFunctions(t *testing.T) {
+	root := coverRoot(t)
+	defer os.RemoveAll(root)
+
+	modules, err := declaredFunctions(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"azure/thing.sh":   {"azure_thing_create", "azure_thing_update", "_azure_thing_private"},
+		"azure/blob/fs.sh": {"azure_blob_fs_upload"},
+		"azure/empty.sh":   {},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Fatalf("expected[%q], got[%q]", want, modules)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	root := coverRoot(t)
+	defer os.RemoveAll(root)
+
+	err := instrument(root, "/bin/klb-tests-cover")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(root, "azure", "thing.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# klb like module
+fn azure_thing_create(name, location) {
+	/bin/klb-tests-cover "azure/thing.sh" "azure_thing_create"
+	azure thing create $name $location
+}
+
+fn azure_thing_update(
+	name,
+	size,
+) {
+	/bin/klb-tests-cover "azure/thing.sh" "azure_thing_update"
+	fn nested() {
+		echo "nested functions are not covered"
+	}
+	nested()
+}
+
+fn _azure_thing_private() {
+	/bin/klb-tests-cover "azure/thing.sh" "_azure_thing_private"
+	return ""
+}
+`
+	if string(got) != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	notes, err := ioutil.ReadFile(filepath.Join(root, "azure", "notes.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(notes) != "fn not_a_module() {\n" {
+		t.Fatalf("expected files other than modules untouched, got:\n%s", notes)
+	}
+}
+
+func TestReadCoverage(t *testing.T) {
+	records, err := ioutil.TempFile("", "klb-tests-coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(records.Name())
+	_, err = records.WriteString(
+		"TestVM\tazure/vm.sh\tazure_vm_create\n" +
+			"TestVM\tazure/vm.sh\tazure_vm_create\n" +
+			"TestNIC\tazure/vm.sh\tazure_vm_create\n" +
+			"TestNIC\tazure/nic.sh\tazure_nic_create\n" +
+			"invalid record\n",
+	)
+	records.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls, err := readCoverage(records.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]map[string]int{
+		"azure/vm.sh":  {"azure_vm_create": {"TestVM": 2, "TestNIC": 1}},
+		"azure/nic.sh": {"azure_nic_create": {"TestNIC": 1}},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected[%v], got[%v]", want, calls)
+	}
+}
+
+func TestSaveCoverageReport(t *testing.T) {
+	root := coverRoot(t)
+	defer os.RemoveAll(root)
+	for _, marker := range []string{"Makefile", "azure/login.sh", "aws/all.sh"} {
+		writeScript(t, filepath.Join(root, marker), "")
+	}
+	defer os.Setenv("KLB_ROOT", os.Getenv("KLB_ROOT"))
+	os.Setenv("KLB_ROOT", root)
+
+	records, err := ioutil.TempFile("", "klb-tests-coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(records.Name())
+	_, err = records.WriteString(
+		"TestThing\tazure/thing.sh\tazure_thing_create\n" +
+			"TestThing\tazure/thing.sh\tazure_thing_create\n" +
+			"TestOther\tazure/thing.sh\tazure_thing_create\n" +
+			"TestThing\tazure/thing.sh\t_azure_thing_private\n",
+	)
+	records.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved string) {
+		coverage.records = saved
+	}(coverage.records)
+	coverage.records = records.Name()
+
+	report := filepath.Join(root, "report.txt")
+	summary, err := SaveCoverageReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != (CoverageSummary{Public: 3, Covered: 1}) {
+		t.Errorf("expected 1 of 3 public functions covered, got[%s]", summary)
+	}
+
+	got, err := ioutil.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `aws/all.sh: 0 of 0 public klb functions covered (0.0%)
+  calls  function  tests
+
+azure/blob/fs.sh: 0 of 1 public klb functions covered (0.0%)
+  calls  function              tests
+  0      azure_blob_fs_upload  UNCOVERED
+
+azure/empty.sh: 0 of 0 public klb functions covered (0.0%)
+  calls  function  tests
+
+azure/login.sh: 0 of 0 public klb functions covered (0.0%)
+  calls  function  tests
+
+azure/thing.sh: 1 of 2 public klb functions covered (50.0%)
+  calls  function              tests
+  3      azure_thing_create    TestOther(1), TestThing(2)
+  0      azure_thing_update    UNCOVERED
+  1      _azure_thing_private  TestThing(1)
+
+total: 1 of 3 public klb functions covered (33.3%)
+`
+	if string(got) != want {
+		t.Errorf("expected report:\n%s\ngot:\n%s", want, got)
+	}
+	if _, err := os.Stat(records.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected coverage records removed, got[%v]", err)
+	}
+}
+
+// coverRoot creates a klb like root dir with modules on the
+// covered sources and a file that is not a module.
+func coverRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "klb-tests-coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"azure/thing.sh":   coverModule,
+		"azure/empty.sh":   "# no functions\n",
+		"azure/notes.txt":  "fn not_a_module() {\n",
+		"azure/blob/fs.sh": "fn azure_blob_fs_upload(dir) {\n\tls $dir\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			os.RemoveAll(root)
+			t.Fatal(err)
+		}
+	}
+	return root
+}
@@ -0,0 +1,9 @@
+// Package nash runs klb scripts and functions from Go tests.
+//
+// Scripts always run on a nash process, so a nash binary must be
+// on PATH. The vendored nash interpreter is not used to run them
+// in-process: it can't parse the klb modules, that use syntax of
+// newer nash releases like "out, status <= cmd", it exports the
+// script environment with os.Setenv, racing between parallel tests,
+// and its exit builtin terminates the test process.
+package nash
@@ -0,0 +1,205 @@
+package nash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// outputTailSize is the number of output lines kept
+// to report when a script fails.
+const outputTailSize = 30
+
+// ExecError is the error returned when a script fails,
+// it has all the information required to understand
+// the failure without going through the logs.
+type ExecError struct {
+	// Cmd is the command line that failed
+	Cmd []string
+	// Elapsed is the time spent running the command
+	Elapsed time.Duration
+	// Status is how the command exited, like: exit status 1
+	Status string
+	// ExitCode is the exit code of the command, -1 if
+	// it did not exit, like when it is killed
+	ExitCode int
+	// Output has the last lines of output of the command
+	Output []string
+	// Err is the cause of the failure
+	Err error
+}
+
+func newExecError(
+	cmd []string,
+	elapsed time.Duration,
+	status string,
+	output *logWriter,
+	err error,
+) *ExecError {
+	return &ExecError{
+		Cmd:      cmd,
+		Elapsed:  elapsed,
+		Status:   status,
+		ExitCode: -1,
+		Output:   output.tailLines(),
+		Err:      err,
+	}
+}
+
+// ScriptFailure returns the exit code and the last output lines,
+// allowing the retrier to classify script failures.
+func (e *ExecError) ScriptFailure() (int, []string) {
+	return e.ExitCode, e.Output
+}
+
+func (e *ExecError) Error() string {
+	return testlog.Redact(fmt.Sprintf(
+		"cmd[%s] failed after[%s] status[%s] error[%s], last output lines:\n%s",
+		strings.Join(e.Cmd, " "),
+		e.Elapsed,
+		e.Status,
+		e.Err,
+		strings.Join(e.Output, "\n"),
+	))
+}
+
+func (s *Shell) exec(ctx context.Context, env []string, name string, args ...string) error {
+	return execCmd(ctx, s.logger.Logger, env, name, args...)
+}
+
+// execCmd runs the given command bound to the given context.
+// If the context is cancelled all processes started by
+// the command are killed.
+func execCmd(
+	ctx context.Context,
+	logger *log.Logger,
+	env []string,
+	name string,
+	args ...string,
+) error {
+	output := newLogWriter(logger)
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// WHY: a process group allows us to kill the command children
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	cmdline := append([]string{name}, args...)
+	start := time.Now()
+
+	err := ctx.Err()
+	if err != nil {
+		return newExecError(cmdline, 0, "not started", output, err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return newExecError(cmdline, 0, "not started", output, err)
+	}
+
+	// WHY: buffered channel avoid goroutine leak
+	result := make(chan error, 1)
+	go func() {
+		result <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		logger.Printf("%s: %s, killing its process group", name, ctx.Err())
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-result
+		err = ctx.Err()
+	}
+	output.flush()
+	if err == nil {
+		return nil
+	}
+
+	status := "unknown"
+	exitcode := -1
+	if cmd.ProcessState != nil {
+		status = cmd.ProcessState.String()
+		if waitstatus, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			exitcode = waitstatus.ExitStatus()
+		}
+	}
+	execerr := newExecError(cmdline, time.Since(start), status, output, err)
+	execerr.ExitCode = exitcode
+	return execerr
+}
+
+// logWriter sends all output to the logger, line by line, keeping
+// the last lines to be reported on errors.
+type logWriter struct {
+	logger *log.Logger
+
+	mutex   sync.Mutex
+	partial []byte
+	tail    []string
+}
+
+func newLogWriter(logger *log.Logger) *logWriter {
+	return &logWriter{logger: logger}
+}
+
+func (l *logWriter) Write(b []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// WHY: a secret split across writes is only
+	// redacted when the whole line is redacted.
+	l.partial = append(l.partial, b...)
+	for {
+		end := bytes.IndexByte(l.partial, '\n')
+		if end < 0 {
+			break
+		}
+		l.writeLine(string(l.partial[:end]))
+		l.partial = l.partial[end+1:]
+	}
+	return len(b), nil
+}
+
+// flush writes the last line when the output
+// does not end with a newline.
+func (l *logWriter) flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.partial) == 0 {
+		return
+	}
+	l.writeLine(string(l.partial))
+	l.partial = nil
+}
+
+func (l *logWriter) writeLine(line string) {
+	// WHY: the tail lines are reported on errors, that
+	// may not go through a logger from the log package.
+	line = testlog.Redact(line)
+	l.logger.Println("nash:" + line)
+
+	l.tail = append(l.tail, line)
+	if len(l.tail) > outputTailSize {
+		l.tail = l.tail[len(l.tail)-outputTailSize:]
+	}
+}
+
+// tailLines returns the last lines written.
+func (l *logWriter) tailLines() []string {
+	l.flush()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return append([]string{}, l.tail...)
+}
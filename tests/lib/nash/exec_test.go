@@ -0,0 +1,58 @@
+package nash
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+func TestLogWriterRedactsSplitSecrets(t *testing.T) {
+	secret := "klb-tests-split-secret"
+	testlog.AddSecret(secret)
+
+	var logs bytes.Buffer
+	output := newLogWriter(log.New(&logs, "", 0))
+	writes := []string{"login with klb-tests-", "split-secret\nfirst", " line done\nlast"}
+	for _, w := range writes {
+		output.Write([]byte(w))
+	}
+	if strings.Contains(logs.String(), "last") {
+		t.Fatalf("partial line logged before flush:\n%s", logs.String())
+	}
+	output.flush()
+
+	want := []string{
+		"nash:login with " + testlog.Redacted,
+		"nash:first line done",
+		"nash:last",
+	}
+	got := strings.Split(strings.TrimSuffix(logs.String(), "\n"), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected logs:\n%s\ngot:\n%s", strings.Join(want, "\n"), logs.String())
+	}
+
+	tail := output.tailLines()
+	if len(tail) != 3 || tail[0] != "login with "+testlog.Redacted {
+		t.Fatalf("unexpected tail lines: %q", tail)
+	}
+}
+
+func TestLogWriterTail(t *testing.T) {
+	var logs bytes.Buffer
+	output := newLogWriter(log.New(&logs, "", 0))
+	for i := 0; i < outputTailSize+10; i++ {
+		output.Write([]byte("line\n"))
+	}
+	output.Write([]byte("unfinished"))
+
+	tail := output.tailLines()
+	if len(tail) != outputTailSize {
+		t.Fatalf("expected %d tail lines, got %d", outputTailSize, len(tail))
+	}
+	if tail[len(tail)-1] != "unfinished" {
+		t.Fatalf("expected unfinished line on tail, got %q", tail[len(tail)-1])
+	}
+}
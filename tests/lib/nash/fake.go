@@ -0,0 +1,117 @@
+package nash
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// fakes has the faked interactions of a shell and the
+// stubbed invocations made by its last script run or call.
+type fakes struct {
+	mutex        sync.Mutex
+	interactions []Interaction
+	calls        []Invocation
+}
+
+// NewOffline creates a shell to unit test klb functions with
+// faked commands, it never reaches the cloud. All az and azure
+// commands invoked must be faked, invocations without a fake fail
+// the call. Calls don't call azure_login and failures are not
+// tried again.
+func NewOffline(
+	ctx context.Context,
+	t *testing.T,
+	logger *testlog.Logger,
+) *Shell {
+	s := New(ctx, t, logger, nil)
+	s.offline = true
+	s.DisableTryAgain()
+	s.Stub("az", s.fakes.stub)
+	s.Stub("azure", s.fakes.stub)
+	return s
+}
+
+// Fake makes invocations with args starting with fake.Args return
+// the fake output and exit code, fake.Args must start with the
+// command name, like: []string{"az", "vm", "show"}. The first
+// matching fake answers the invocation.
+//
+// Faking a command replaces its stub, so if jq is faked all its
+// invocations must have a fake.
+func (s *Shell) Fake(fake Interaction) {
+	if len(fake.Args) == 0 || !isShimmed(fake.Args[0]) {
+		s.t.Fatalf("invalid fake args %q, can fake only: %v", fake.Args, shimmedCommands)
+	}
+	s.fakes.mutex.Lock()
+	s.fakes.interactions = append(s.fakes.interactions, fake)
+	s.fakes.mutex.Unlock()
+	s.Stub(fake.Args[0], s.fakes.stub)
+}
+
+// Calls returns the invocations of the stubbed and faked
+// commands made by the last script run or call, in order.
+func (s *Shell) Calls() Transcript {
+	s.fakes.mutex.Lock()
+	defer s.fakes.mutex.Unlock()
+	return Transcript{Invocations: append([]Invocation{}, s.fakes.calls...)}
+}
+
+// AssertCalls asserts that the last script run or call invoked
+// exactly the given command lines on the stubbed and faked
+// commands, in order.
+func (s *Shell) AssertCalls(cmdlines ...[]string) {
+	got := [][]string{}
+	for _, invocation := range s.Calls().Invocations {
+		got = append(got, invocation.Args)
+	}
+	if len(cmdlines) == 0 {
+		cmdlines = [][]string{}
+	}
+	if !reflect.DeepEqual(got, cmdlines) {
+		s.t.Fatalf(
+			"calls mismatch, expected:\n%s\ngot:\n%s",
+			formatCmdlines(cmdlines),
+			formatCmdlines(got),
+		)
+	}
+}
+
+func (f *fakes) stub(args []string) (Interaction, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, fake := range f.interactions {
+		if hasArgsPrefix(args, fake.Args) {
+			fake.Args = args
+			return fake, true
+		}
+	}
+	return Interaction{}, false
+}
+
+// record saves the invocations answered by the given stubs.
+func (f *fakes) record(invocations []Invocation, stubs map[string]Stub) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = []Invocation{}
+	for _, invocation := range invocations {
+		if _, ok := stubs[invocation.Args[0]]; ok {
+			f.calls = append(f.calls, invocation)
+		}
+	}
+}
+
+func formatCmdlines(cmdlines [][]string) string {
+	lines := []string{}
+	for _, cmdline := range cmdlines {
+		lines = append(lines, fmt.Sprintf("\t%q", cmdline))
+	}
+	return strings.Join(lines, "\n")
+}
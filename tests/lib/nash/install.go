@@ -0,0 +1,338 @@
+package nash
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// klbsources are the paths, relative to the klb root dir,
+// that are installed by make install.
+var klbsources = []string{
+	"aws",
+	"azure",
+	"tools/azure/getcredentials.sh",
+	"tools/azure/createsp.sh",
+}
+
+// installPrefix is the name prefix of the klb installs on TMPDIR.
+const installPrefix = "klb-tests-nashpath-"
+
+// installMaxAge is how long an install not used by any test
+// process is kept before it is removed.
+const installMaxAge = 7 * 24 * time.Hour
+
+// install is the klb installation shared by all shells on the
+// test process, it is done only once.
+var install struct {
+	once     sync.Once
+	root     string
+	digest   string
+	stat     string
+	nashpath string
+	err      error
+}
+
+// installKLB installs klb on a NASHPATH shared by all the tests,
+// returning its path. The install is done only once per test
+// process and it is reused by other test processes as long as the
+// klb sources did not change, so it must be used read only.
+//
+// The klb root dir is the KLB_ROOT environment variable or the
+// first parent of the current dir containing klb, since tests
+// run inside the project.
+//
+// If the klb sources changed after the install an error is
+// returned, since tests would run against stale code. Changes
+// are detected by the size and modification time of the sources,
+// their contents are hashed only once.
+//
+// Installs not used for installMaxAge are removed, including the
+// ones of other klb sources.
+//
+// When coverage is enabled klb is installed instrumented
+// to record the calls of its functions.
+func installKLB(logger *log.Logger) (string, error) {
+	install.once.Do(func() {
+		install.root, install.err = klbroot()
+		if install.err != nil {
+			return
+		}
+		install.stat, install.err = klbstat(install.root)
+		if install.err != nil {
+			return
+		}
+		install.digest, install.err = klbdigest(install.root)
+		if install.err != nil {
+			return
+		}
+		install.nashpath, install.err = makeInstall(
+			logger,
+			install.root,
+			install.digest,
+			coverage.enabled,
+		)
+		if install.err != nil {
+			return
+		}
+		err := pruneInstalls(os.TempDir(), install.nashpath, time.Now().Add(-installMaxAge))
+		if err != nil {
+			logger.Printf("error removing old klb installs: %s", err)
+		}
+	})
+
+	if install.err != nil {
+		return "", install.err
+	}
+
+	stat, err := klbstat(install.root)
+	if err != nil {
+		return "", err
+	}
+	if stat != install.stat {
+		return "", fmt.Errorf(
+			"klb installed at[%s] is stale, sources at[%s] changed after the tests started",
+			install.nashpath,
+			install.root,
+		)
+	}
+	return install.nashpath, nil
+}
+
+func makeInstall(
+	logger *log.Logger,
+	root string,
+	digest string,
+	instrumented bool,
+) (string, error) {
+	name := installPrefix + digest[:16]
+	if instrumented {
+		name += "-coverage"
+	}
+	nashpath := filepath.Join(os.TempDir(), name)
+	if _, err := os.Stat(nashpath); err == nil {
+		logger.Printf("reusing klb installed at %s", nashpath)
+		// WHY: the modification time tells when it was last used
+		now := time.Now()
+		return nashpath, os.Chtimes(nashpath, now, now)
+	}
+
+	logger.Printf("installing klb from %s", root)
+	tmpdir, err := ioutil.TempDir("", "klb-tests-install")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		readwrite(tmpdir)
+		os.RemoveAll(tmpdir)
+	}()
+
+	cmd := exec.Command("make", "install")
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "NASHPATH="+tmpdir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error[%s] running make install: %s", err, out)
+	}
+
+	if instrumented {
+		err = ioutil.WriteFile(filepath.Join(tmpdir, "bin", "klb-tests-cover"), []byte(coverShim), 0755)
+		if err != nil {
+			return "", err
+		}
+		// WHY: the shim is called from where the install is moved to
+		err = instrument(
+			filepath.Join(tmpdir, "lib", "klb"),
+			filepath.Join(nashpath, "bin", "klb-tests-cover"),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = readonly(tmpdir)
+	if err != nil {
+		return "", err
+	}
+
+	// WHY: rename is atomic, other test processes running at the
+	// same time will never see a partial install.
+	err = os.Rename(tmpdir, nashpath)
+	if err != nil {
+		if _, staterr := os.Stat(nashpath); staterr == nil {
+			// Some other test process installed it first
+			return nashpath, nil
+		}
+		return "", err
+	}
+	logger.Printf("installed klb at %s", nashpath)
+	return nashpath, nil
+}
+
+func klbroot() (string, error) {
+	if root := os.Getenv("KLB_ROOT"); root != "" {
+		if !isKLBRoot(root) {
+			return "", fmt.Errorf("KLB_ROOT[%s] is not a klb project dir", root)
+		}
+		return root, nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if isKLBRoot(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New(
+				"unable to find klb project dir on parents of current dir, set KLB_ROOT",
+			)
+		}
+		dir = parent
+	}
+}
+
+func isKLBRoot(dir string) bool {
+	for _, marker := range []string{"Makefile", "azure/login.sh", "aws/all.sh"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneInstalls removes the klb installs on tmpdir, and the
+// leftovers of failed installs, modified before the given time.
+// The install at nashpath, used by this test process, is kept.
+func pruneInstalls(tmpdir string, nashpath string, before time.Time) error {
+	entries, err := ioutil.ReadDir(tmpdir)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(tmpdir, name)
+		if !entry.IsDir() ||
+			path == nashpath ||
+			!entry.ModTime().Before(before) ||
+			!(strings.HasPrefix(name, installPrefix) ||
+				strings.HasPrefix(name, "klb-tests-install")) {
+			continue
+		}
+		// WHY: installs are read only, RemoveAll would fail
+		err := readwrite(path)
+		if err == nil {
+			err = os.RemoveAll(path)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// klbfiles returns the paths of all klb sources that are installed.
+func klbfiles(root string) ([]string, error) {
+	var files []string
+	for _, source := range klbsources {
+		err := filepath.Walk(
+			filepath.Join(root, source),
+			func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() {
+					files = append(files, path)
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// klbstat calculates a digest of the path, size and modification
+// time of all klb sources that are installed, without reading them.
+func klbstat(root string) (string, error) {
+	files, err := klbfiles(root)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// klbdigest calculates a digest of all klb sources that are installed.
+func klbdigest(root string) (string, error) {
+	files, err := klbfiles(root)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		relpath, _ := filepath.Rel(root, path)
+		io.WriteString(hash, relpath)
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func readonly(dir string) error {
+	return chmodAll(dir, 0555, 0444)
+}
+
+func readwrite(dir string) error {
+	return chmodAll(dir, 0755, 0644)
+}
+
+func chmodAll(dir string, dirmode os.FileMode, filemode os.FileMode) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.Chmod(path, dirmode)
+		}
+		if info.Mode()&0111 != 0 {
+			return os.Chmod(path, filemode|0111)
+		}
+		return os.Chmod(path, filemode)
+	})
+}
@@ -0,0 +1,101 @@
+package nash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneInstalls(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "klb-tests-prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		readwrite(tmpdir)
+		os.RemoveAll(tmpdir)
+	}()
+
+	now := time.Now()
+	old := now.Add(-2 * installMaxAge)
+	dirs := map[string]time.Time{
+		installPrefix + "current":      old,
+		installPrefix + "old":          old,
+		installPrefix + "old-coverage": old,
+		installPrefix + "recent":       now,
+		"klb-tests-install123":         old,
+		"unrelated":                    old,
+	}
+	for name, modtime := range dirs {
+		dir := filepath.Join(tmpdir, name)
+		writeScript(t, filepath.Join(dir, "lib", "klb", "azure", "vm.sh"), "fn azure_vm_create() {}\n")
+		err := readonly(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(dir, modtime, modtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nashpath := filepath.Join(tmpdir, installPrefix+"current")
+	err = pruneInstalls(tmpdir, nashpath, now.Add(-installMaxAge))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		installPrefix + "current":      true,
+		installPrefix + "old":          false,
+		installPrefix + "old-coverage": false,
+		installPrefix + "recent":       true,
+		"klb-tests-install123":         false,
+		"unrelated":                    true,
+	}
+	for name, exists := range want {
+		_, err := os.Stat(filepath.Join(tmpdir, name))
+		if exists != (err == nil) {
+			t.Errorf("%s: expected exists=%t, got error[%v]", name, exists, err)
+		}
+	}
+}
+
+func TestKLBStat(t *testing.T) {
+	root, err := ioutil.TempDir("", "klb-tests-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, source := range klbsources {
+		path := filepath.Join(root, source)
+		if filepath.Ext(path) != ".sh" {
+			path = filepath.Join(path, "module.sh")
+		}
+		writeScript(t, path, "fn f() {}\n")
+	}
+
+	before, err := klbstat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchanged, err := klbstat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != unchanged {
+		t.Fatal("stat digest changed without changes on the sources")
+	}
+
+	writeScript(t, filepath.Join(root, "azure", "module.sh"), "fn f() { echo changed }\n")
+	after, err := klbstat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Fatal("stat digest did not change after changing a source")
+	}
+}
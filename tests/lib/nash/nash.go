@@ -6,19 +6,30 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/NeowayLabs/klb/tests/lib/cassette"
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/ratelimit"
 	"github.com/NeowayLabs/klb/tests/lib/retrier"
+	"github.com/NeowayLabs/klb/tests/lib/telemetry"
 )
 
 type Shell struct {
 	ctx     context.Context
 	t       *testing.T
 	retrier *retrier.Retrier
-	logger  *log.Logger
+	logger  *testlog.Logger
 	env     []string
+	offline bool
+	worker  *worker
+
+	transcript *transcriber
+	cassette   *cassette.Cassette
+	stubs      map[string]Stub
+	fakes      *fakes
 }
 
 // New creates a new Shell instance.
@@ -31,15 +42,16 @@ type Shell struct {
 func New(
 	ctx context.Context,
 	t *testing.T,
-	logger *log.Logger,
+	logger *testlog.Logger,
 	env []string,
 ) *Shell {
 	return &Shell{
 		ctx:     ctx,
 		t:       t,
-		retrier: retrier.New(ctx, t, logger),
+		retrier: retrier.New(ctx, t, logger, retrier.Slow),
 		logger:  logger,
 		env:     env,
+		fakes:   &fakes{},
 	}
 }
 
@@ -50,12 +62,22 @@ func (s *Shell) DisableTryAgain() {
 	s.retrier.Disable()
 }
 
+// ClassifyFailures classifies script failures with the given
+// rules before the Azure rules, like failing right away when
+// a script exits with a status that means a usage error.
+func (s *Shell) ClassifyFailures(rules ...retrier.ScriptRule) {
+	s.retrier.SetClassifier(retrier.Classifiers(
+		retrier.ScriptClassifier(rules...),
+		retrier.Azure,
+	))
+}
+
 func (s *Shell) Run(
 	scriptpath string,
 	args ...string,
 ) {
-	s.retrier.Run("Shell.Run:"+scriptpath, func() error {
-		return s.RunOnce(scriptpath, args...)
+	s.retrier.RunContext("Shell.Run:"+scriptpath, func(ctx context.Context) error {
+		return s.runOnce(ctx, scriptpath, args...)
 	})
 }
 
@@ -63,64 +85,119 @@ func (s *Shell) RunOnce(
 	scriptpath string,
 	args ...string,
 ) error {
-	s.logger.Printf("running: %s", scriptpath)
-	completeargs := []string{scriptpath}
-	completeargs = append(completeargs, args...)
+	return s.runOnce(s.ctx, scriptpath, args...)
+}
 
-	homedir, err := ioutil.TempDir("", "klb-tests")
-	defer func() {
-		err := os.RemoveAll(homedir)
-		if err != nil {
-			s.t.Errorf("error removing tmp dir: %s", err)
-		}
-	}()
+// runOnce runs the script bound to the given context,
+// killing it when the context is cancelled.
+func (s *Shell) runOnce(
+	ctx context.Context,
+	scriptpath string,
+	args ...string,
+) error {
+	s = s.logging(testlog.Fields{Script: scriptpath})
+	s.logger.Printf("running: %s", scriptpath)
+	err := s.rateLimit(ctx)
 	if err != nil {
-		s.t.Fatalf("unable to create tmp dir: %s", err)
+		return err
 	}
-	nashdir := homedir + "/.nash"
-	env := append(
-		s.env,
-		fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
-		fmt.Sprintf("HOME=%s", homedir),
-		fmt.Sprintf("NASHPATH=%s", nashdir),
-	)
-	s.installKLB(env)
-	err = s.newcmd(env, scriptpath, args...).Run()
+	start := time.Now()
+
+	err = s.isolated(func(env []string, homedir string, nashpath string) error {
+		if s.shimmed() {
+			return s.runShimmed(ctx, env, homedir, scriptpath, args...)
+		}
+		return s.exec(ctx, env, scriptpath, args...)
+	})
 	s.logger.Printf("%s result: %+v", scriptpath, err)
+	recordScript(s.t, filepath.Base(scriptpath), start, err)
 	return err
 }
 
-func (s *Shell) newcmd(env []string, name string, args ...string) *exec.Cmd {
-	log := &logWriter{logger: s.logger}
-	cmd := exec.Command(name, args...)
-	cmd.Env = env
-	cmd.Stdout = log
-	cmd.Stderr = log
-	return cmd
+// shimmed tells if the commands invoked by scripts
+// must be replaced by shims.
+func (s *Shell) shimmed() bool {
+	return s.transcript != nil || s.cassette != nil || len(s.stubs) > 0
 }
 
-func (s *Shell) installKLB(env []string) {
-	s.logger.Println("installing klb on isolated test NASHPATH")
-	cmd := s.newcmd(env, "make", "install")
-	cmd.Dir = klbprojectdir()
-	err := cmd.Run()
-	if err != nil {
-		s.t.Fatalf("unable to install klb on tmp isolated NASHPATH: %s", err)
-		return
+// logging returns a copy of the shell logging with the given
+// fields, all the other state is shared.
+func (s *Shell) logging(fields testlog.Fields) *Shell {
+	c := *s
+	c.logger = s.logger.With(fields)
+	return &c
+}
+
+// rateLimit waits on the subscription rate limit before
+// running something that may call Azure, offline runs don't wait.
+func (s *Shell) rateLimit(ctx context.Context) error {
+	if len(s.stubs) > 0 || (s.cassette != nil && s.cassette.Replaying()) {
+		return nil
 	}
-	s.logger.Println("successfully installed klb")
+	return ratelimit.Subscription.Wait(ctx, s.t.Name(), ratelimit.Write)
+}
 
+// recordScript sends the execution of a script
+// or function to telemetry.
+func recordScript(t *testing.T, name string, start time.Time, err error) {
+	telemetry.RecordScript(telemetry.Script{
+		Name:     name,
+		Test:     t.Name(),
+		Duration: time.Since(start),
+		Success:  err == nil,
+	})
 }
 
-func klbprojectdir() string {
-	return os.Getenv("GOPATH") + "/src/github.com/NeowayLabs/klb"
+// isolated calls run with the environment of a new isolated
+// HOME, removing it afterwards. The NASHPATH with klb installed
+// is shared by all runs and must be used read only.
+func (s *Shell) isolated(run func(env []string, homedir string, nashpath string) error) error {
+	sandbox, err := newSandbox(s.logger.Logger, s.env)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer func() {
+		err := sandbox.remove()
+		if err != nil {
+			s.t.Error(err)
+		}
+	}()
+	env := append(sandbox.env, coverageEnv(s.t.Name())...)
+	return run(env, sandbox.homedir, sandbox.nashpath)
 }
 
-type logWriter struct {
-	logger *log.Logger
+// sandbox is an isolated HOME with klb installed on NASHPATH.
+type sandbox struct {
+	env      []string
+	homedir  string
+	nashpath string
 }
 
-func (l *logWriter) Write(b []byte) (int, error) {
-	l.logger.Println("nash:" + strings.TrimSuffix(string(b), "\n"))
-	return len(b), nil
+func newSandbox(logger *log.Logger, env []string) (*sandbox, error) {
+	nashpath, err := installKLB(logger)
+	if err != nil {
+		return nil, fmt.Errorf("klb install: %s", err)
+	}
+	homedir, err := ioutil.TempDir("", "klb-tests")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	return &sandbox{
+		env: append(
+			env,
+			fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
+			fmt.Sprintf("HOME=%s", homedir),
+			fmt.Sprintf("NASHPATH=%s", nashpath),
+		),
+		homedir:  homedir,
+		nashpath: nashpath,
+	}, nil
+}
+
+func (s *sandbox) remove() error {
+	err := os.RemoveAll(s.homedir)
+	if err != nil {
+		return fmt.Errorf("error removing tmp dir: %s", err)
+	}
+	return nil
 }
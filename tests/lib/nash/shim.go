@@ -0,0 +1,314 @@
+package nash
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/retrier"
+)
+
+// shimmedCommands are the commands replaced by shims
+// when recording transcripts, using cassettes or stubs.
+var shimmedCommands = []string{"az", "azure", "aws", "jq"}
+
+// recordShimTemplate records the invocation on a dir and runs the
+// real command, streaming its output through tee as it is written.
+const recordShimTemplate = `#!/bin/sh
+# klb tests shim: records the invocation and runs the real command
+record="$KLB_TESTS_RECORDS/$(date +%%s%%N)-$$"
+mkdir -p "$record"
+for arg in %s "$@"; do printf '%%s\0' "$arg"; done > "$record/args"
+pwd > "$record/dir"
+env -0 > "$record/env"
+mkfifo "$record/stdout.fifo" "$record/stderr.fifo"
+tee "$record/stdout" < "$record/stdout.fifo" &
+stdoutpid=$!
+tee "$record/stderr" < "$record/stderr.fifo" >&2 &
+stderrpid=$!
+date +%%s%%N > "$record/start"
+%s "$@" > "$record/stdout.fifo" 2> "$record/stderr.fifo"
+status=$?
+date +%%s%%N > "$record/end"
+wait $stdoutpid $stderrpid
+rm -f "$record/stdout.fifo" "$record/stderr.fifo"
+echo $status > "$record/status"
+exit $status
+`
+
+// stubShimTemplate sends the invocation as a request dir and
+// waits for the response, that is complete when status exists.
+const stubShimTemplate = `#!/bin/sh
+# klb tests shim: the command is answered by a stub
+request="$KLB_TESTS_REQUESTS/$(date +%%s%%N)-$$"
+mkdir -p "$request.tmp"
+for arg in %s "$@"; do printf '%%s\0' "$arg"; done > "$request.tmp/args"
+mv "$request.tmp" "$request"
+while [ ! -f "$request/status" ]; do sleep 0.01; done
+cat "$request/stdout"
+cat "$request/stderr" >&2
+exit $(cat "$request/status")
+`
+
+// Interaction is the answer to an invocation of a command.
+type Interaction struct {
+	Args     []string `json:"args"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+}
+
+// Stub answers the invocations of a stubbed command, args
+// includes the command name. It returns false when it has
+// no answer for the invocation.
+type Stub func(args []string) (Interaction, bool)
+
+// Stub replaces the given command by the stub on all scripts
+// run by the shell, only az, azure, aws and jq can be stubbed.
+// Invocations the stub has no answer for make the script run fail.
+//
+// Calls are stubbed too, they don't run on the worker
+// while there are stubs.
+func (s *Shell) Stub(command string, stub Stub) {
+	if !isShimmed(command) {
+		s.t.Fatalf("command[%s] can't be stubbed, stubbed commands: %v", command, shimmedCommands)
+	}
+	if s.stubs == nil {
+		s.stubs = map[string]Stub{}
+	}
+	s.stubs[command] = stub
+}
+
+// RunStubbed runs the script on a nash process with the given
+// commands stubbed, returning the invocations of all az, azure,
+// aws and jq commands. It does not depend on a test, so it can
+// be used by commands.
+func RunStubbed(
+	ctx context.Context,
+	logger *log.Logger,
+	env []string,
+	stubs map[string]Stub,
+	scriptpath string,
+	args ...string,
+) ([]Invocation, error) {
+	sandbox, err := newSandbox(logger, env)
+	if err != nil {
+		return nil, err
+	}
+	defer sandbox.remove()
+
+	invocations, unexpected, err := runShimmed(
+		ctx, logger, sandbox.env, sandbox.homedir, stubs, scriptpath, args...,
+	)
+	if len(unexpected) > 0 {
+		return invocations, fmt.Errorf(
+			"stubs have no response for calls:\n%s",
+			strings.Join(unexpected, "\n"),
+		)
+	}
+	return invocations, err
+}
+
+// runShimmed runs the script recording the invocations of
+// the shimmed commands on the transcript/cassette, stubbed
+// commands are answered by their stubs.
+func (s *Shell) runShimmed(
+	ctx context.Context,
+	env []string,
+	homedir string,
+	scriptpath string,
+	args ...string,
+) error {
+	stubs := s.stubs
+	if s.cassette != nil {
+		s.cassette.SetContext(env)
+		if s.cassette.Replaying() {
+			stubs = s.cassetteStubs()
+		}
+	}
+
+	invocations, unexpected, err := runShimmed(
+		ctx, s.logger.Logger, env, homedir, stubs, scriptpath, args...,
+	)
+	if invocations == nil {
+		return err
+	}
+	s.fakes.record(invocations, s.stubs)
+	if len(unexpected) > 0 {
+		source := "stubs have"
+		if s.cassette != nil && s.cassette.Replaying() {
+			source = fmt.Sprintf("cassette[%s] has", s.cassette.Path())
+		}
+		// WHY: trying again would get the same answer
+		return retrier.Abort(fmt.Errorf(
+			"%s no response for calls:\n%s",
+			source,
+			strings.Join(unexpected, "\n"),
+		))
+	}
+
+	if s.transcript != nil {
+		saveerr := s.transcript.record(invocations)
+		if saveerr != nil {
+			s.t.Errorf("error saving transcript: %s", saveerr)
+		}
+	}
+	if s.cassette != nil && !s.cassette.Replaying() {
+		s.recordCassette(invocations)
+	}
+	return err
+}
+
+// runShimmed runs the script with the shimmed commands on PATH,
+// returning their invocations ordered by start time and the ones
+// the stubs had no answer for. Commands without stubs run for real.
+func runShimmed(
+	ctx context.Context,
+	logger *log.Logger,
+	env []string,
+	homedir string,
+	stubs map[string]Stub,
+	scriptpath string,
+	args ...string,
+) ([]Invocation, []string, error) {
+	shimsdir := filepath.Join(homedir, "klb-tests-shims")
+	recordsdir := filepath.Join(homedir, "klb-tests-records")
+	requestsdir := filepath.Join(homedir, "klb-tests-requests")
+
+	for _, dir := range []string{shimsdir, recordsdir, requestsdir} {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, name := range shimmedCommands {
+		var shim string
+		if _, ok := stubs[name]; ok {
+			shim = fmt.Sprintf(stubShimTemplate, shellquote(name))
+		} else {
+			realpath, err := exec.LookPath(name)
+			if err != nil {
+				continue
+			}
+			shim = fmt.Sprintf(recordShimTemplate, shellquote(name), shellquote(realpath))
+		}
+		err := ioutil.WriteFile(filepath.Join(shimsdir, name), []byte(shim), 0755)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	env = append(
+		env,
+		fmt.Sprintf("PATH=%s:%s", shimsdir, os.Getenv("PATH")),
+		fmt.Sprintf("KLB_TESTS_RECORDS=%s", recordsdir),
+		fmt.Sprintf("KLB_TESTS_REQUESTS=%s", requestsdir),
+	)
+
+	stubber := startStubber(stubs, requestsdir, scriptpath)
+	err := execCmd(ctx, logger, env, scriptpath, args...)
+	stubbed, unexpected := stubber.stop()
+
+	invocations, recerr := readInvocations(recordsdir, scriptpath)
+	if recerr != nil {
+		return nil, nil, fmt.Errorf(
+			"error reading records of %s: %s, script error: %v",
+			scriptpath, recerr, err,
+		)
+	}
+
+	invocations = append(invocations, stubbed...)
+	sort.SliceStable(invocations, func(i, j int) bool {
+		return invocations[i].Start.Before(invocations[j].Start)
+	})
+	return invocations, unexpected, err
+}
+
+func isShimmed(command string) bool {
+	for _, name := range shimmedCommands {
+		if name == command {
+			return true
+		}
+	}
+	return false
+}
+
+func readInvocations(recordsdir string, scriptpath string) ([]Invocation, error) {
+	records, err := ioutil.ReadDir(recordsdir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, record := range records {
+		names = append(names, record.Name())
+	}
+	// WHY: records are named after their start time
+	sort.Strings(names)
+
+	invocations := []Invocation{}
+	for _, name := range names {
+		invocation, err := readInvocation(filepath.Join(recordsdir, name))
+		if err != nil {
+			return nil, err
+		}
+		invocation.Script = scriptpath
+		invocations = append(invocations, invocation)
+	}
+	return invocations, nil
+}
+
+func readInvocation(recorddir string) (Invocation, error) {
+	read := func(name string) string {
+		data, _ := ioutil.ReadFile(filepath.Join(recorddir, name))
+		return string(data)
+	}
+	readtime := func(name string) time.Time {
+		nanos, err := strconv.ParseInt(strings.TrimSpace(read(name)), 10, 64)
+		if err != nil {
+			return time.Time{}
+		}
+		return time.Unix(0, nanos)
+	}
+
+	invocation := Invocation{
+		Args:     readArgs(read("args")),
+		Dir:      strings.TrimSpace(read("dir")),
+		Env:      map[string]string{},
+		ExitCode: -1,
+		Stdout:   read("stdout"),
+		Stderr:   read("stderr"),
+		Start:    readtime("start"),
+	}
+
+	// WHY: when the command is killed the status is never recorded
+	if status, err := strconv.Atoi(strings.TrimSpace(read("status"))); err == nil {
+		invocation.ExitCode = status
+		invocation.Duration = readtime("end").Sub(invocation.Start)
+	}
+
+	for _, envvar := range strings.Split(read("env"), "\x00") {
+		parsed := strings.SplitN(envvar, "=", 2)
+		if len(parsed) != 2 || !isRecordedEnv(parsed[0]) {
+			continue
+		}
+		invocation.Env[parsed[0]] = parsed[1]
+	}
+	return invocation, nil
+}
+
+func readArgs(args string) []string {
+	return strings.Split(strings.TrimSuffix(args, "\x00"), "\x00")
+}
+
+func shellquote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,136 @@
+package nash
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAz prints on stdout and stderr with pauses,
+// so the order of its output is known.
+const fakeAz = `#!/bin/sh
+echo "out1 $*"
+sleep 0.1
+echo err1 >&2
+sleep 0.1
+echo out2
+exit 3
+`
+
+func TestRecordShimStreamsOutput(t *testing.T) {
+	homedir, err := ioutil.TempDir("", "klb-tests-shim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(homedir)
+
+	bindir := filepath.Join(homedir, "bin")
+	writeScript(t, filepath.Join(bindir, "az"), fakeAz)
+	scriptpath := filepath.Join(homedir, "script.sh")
+	writeScript(t, scriptpath, "#!/bin/sh\naz vm list --output json\n")
+
+	// WHY: the real command is found on the test process PATH
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", bindir+":"+os.Getenv("PATH"))
+
+	var logs bytes.Buffer
+	invocations, unexpected, err := runShimmed(
+		context.Background(),
+		log.New(&logs, "", 0),
+		[]string{"PATH=" + os.Getenv("PATH")},
+		homedir,
+		map[string]Stub{},
+		scriptpath,
+	)
+	if err == nil {
+		t.Fatal("expected script to fail with the az exit status")
+	}
+	if len(unexpected) != 0 {
+		t.Fatalf("unexpected calls: %q", unexpected)
+	}
+
+	wantLogs := "nash:out1 vm list --output json\nnash:err1\nnash:out2\n"
+	if logs.String() != wantLogs {
+		t.Fatalf("expected output in order:\n%s\ngot:\n%s", wantLogs, logs.String())
+	}
+
+	if len(invocations) != 1 {
+		t.Fatalf("expected 1 invocation, got %d", len(invocations))
+	}
+	invocation := invocations[0]
+	want := Invocation{
+		Args:     []string{"az", "vm", "list", "--output", "json"},
+		ExitCode: 3,
+		Stdout:   "out1 vm list --output json\nout2\n",
+		Stderr:   "err1\n",
+	}
+	if strings.Join(invocation.Args, " ") != strings.Join(want.Args, " ") {
+		t.Errorf("expected args %q, got %q", want.Args, invocation.Args)
+	}
+	if invocation.ExitCode != want.ExitCode {
+		t.Errorf("expected exit code %d, got %d", want.ExitCode, invocation.ExitCode)
+	}
+	if invocation.Stdout != want.Stdout || invocation.Stderr != want.Stderr {
+		t.Errorf(
+			"expected stdout[%q] stderr[%q], got stdout[%q] stderr[%q]",
+			want.Stdout, want.Stderr, invocation.Stdout, invocation.Stderr,
+		)
+	}
+	if invocation.Duration <= 0 {
+		t.Errorf("expected duration to be recorded, got %s", invocation.Duration)
+	}
+}
+
+func TestStubShim(t *testing.T) {
+	homedir, err := ioutil.TempDir("", "klb-tests-shim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(homedir)
+
+	scriptpath := filepath.Join(homedir, "script.sh")
+	writeScript(t, scriptpath, "#!/bin/sh\naz group show --name klb\naz group delete --name klb\n")
+
+	stubs := map[string]Stub{
+		"az": func(args []string) (Interaction, bool) {
+			if hasArgsPrefix(args, []string{"az", "group", "show"}) {
+				return Interaction{Args: args, Stdout: "{}\n"}, true
+			}
+			return Interaction{}, false
+		},
+	}
+	var logs bytes.Buffer
+	invocations, unexpected, err := runShimmed(
+		context.Background(),
+		log.New(&logs, "", 0),
+		[]string{"PATH=" + os.Getenv("PATH")},
+		homedir,
+		stubs,
+		scriptpath,
+	)
+	if err == nil {
+		t.Fatal("expected script to fail on the unexpected call")
+	}
+	if strings.Join(unexpected, "\n") != "az group delete --name klb" {
+		t.Fatalf("unexpected calls: %q", unexpected)
+	}
+	if len(invocations) != 2 || invocations[0].Stdout != "{}\n" {
+		t.Fatalf("unexpected invocations: %+v", invocations)
+	}
+}
+
+func writeScript(t *testing.T, path string, content string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, []byte(content), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
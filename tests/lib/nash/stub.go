@@ -0,0 +1,122 @@
+package nash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stubber answers the requests made by the stub shims.
+type stubber struct {
+	stubs       map[string]Stub
+	requestsdir string
+	scriptpath  string
+
+	done       chan struct{}
+	stopped    sync.WaitGroup
+	stubbed    []Invocation
+	unexpected []string
+}
+
+func startStubber(stubs map[string]Stub, requestsdir string, scriptpath string) *stubber {
+	s := &stubber{
+		stubs:       stubs,
+		requestsdir: requestsdir,
+		scriptpath:  scriptpath,
+		done:        make(chan struct{}),
+	}
+	s.stopped.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *stubber) run() {
+	defer s.stopped.Done()
+
+	answered := map[string]bool{}
+	for {
+		s.answer(answered)
+		select {
+		case <-s.done:
+			// WHY: requests made just before the script ended
+			s.answer(answered)
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *stubber) answer(answered map[string]bool) {
+	requests, err := ioutil.ReadDir(s.requestsdir)
+	if err != nil {
+		return
+	}
+	names := []string{}
+	for _, request := range requests {
+		name := request.Name()
+		if strings.HasSuffix(name, ".tmp") || answered[name] {
+			continue
+		}
+		names = append(names, name)
+	}
+	// WHY: requests are named after their start time
+	sort.Strings(names)
+
+	for _, name := range names {
+		answered[name] = true
+		requestdir := filepath.Join(s.requestsdir, name)
+		args, err := ioutil.ReadFile(filepath.Join(requestdir, "args"))
+		if err != nil {
+			continue
+		}
+		s.respond(requestdir, readArgs(string(args)))
+	}
+}
+
+func (s *stubber) respond(requestdir string, args []string) {
+	start := time.Now()
+	interaction, ok := s.stubs[args[0]](args)
+	if !ok {
+		s.unexpected = append(s.unexpected, strings.Join(args, " "))
+		interaction = Interaction{
+			Stderr:   fmt.Sprintf("klb tests: no stubbed response for: %s\n", strings.Join(args, " ")),
+			ExitCode: 1,
+		}
+	}
+
+	write := func(name string, content string) {
+		ioutil.WriteFile(filepath.Join(requestdir, name), []byte(content), 0644)
+	}
+	write("stdout", interaction.Stdout)
+	write("stderr", interaction.Stderr)
+	write("status.tmp", fmt.Sprintf("%d\n", interaction.ExitCode))
+	// WHY: the shim waits for the status, it must be complete
+	os.Rename(
+		filepath.Join(requestdir, "status.tmp"),
+		filepath.Join(requestdir, "status"),
+	)
+
+	s.stubbed = append(s.stubbed, Invocation{
+		Script:   s.scriptpath,
+		Args:     args,
+		Env:      map[string]string{},
+		ExitCode: interaction.ExitCode,
+		Stdout:   interaction.Stdout,
+		Stderr:   interaction.Stderr,
+		Start:    start,
+		Duration: time.Since(start),
+	})
+}
+
+// stop stops answering requests, returning the stubbed
+// invocations and the unexpected ones.
+func (s *stubber) stop() ([]Invocation, []string) {
+	close(s.done)
+	s.stopped.Wait()
+	return s.stubbed, s.unexpected
+}
@@ -0,0 +1,167 @@
+package nash
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// Transcript has all the commands invoked by scripts, in order.
+type Transcript struct {
+	Invocations []Invocation `json:"invocations"`
+}
+
+// Invocation is a command invoked by a script.
+type Invocation struct {
+	// Script is the script that invoked the command
+	Script string `json:"script"`
+	// Args is the complete command line, including the command name
+	Args []string `json:"args"`
+	// Dir is the working dir of the command
+	Dir string `json:"dir"`
+	// Env has the environment of the command, without secrets
+	Env      map[string]string `json:"env"`
+	ExitCode int               `json:"exit_code"`
+	Stdout   string            `json:"stdout"`
+	Stderr   string            `json:"stderr"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration_ns"`
+}
+
+// recordedEnv are the prefixes of the environment variables
+// recorded on transcripts, variables that seem to have secrets
+// are never recorded.
+var recordedEnv = []string{"AZURE_", "AWS_", "HOME", "NASHPATH"}
+
+var secretEnv = []string{"SECRET", "PASSWORD", "TOKEN", "KEY"}
+
+// EnableTranscript records all the az, azure, aws and jq commands
+// invoked by the scripts, saving the transcript along the logs of
+// the given testname as a JSON file.
+// Calls are recorded too, they don't run on the worker
+// while the transcript is enabled.
+func (s *Shell) EnableTranscript(testname string) {
+	s.transcript = &transcriber{
+		path:       testlog.Path(s.t, testname, ".transcript.json"),
+		transcript: Transcript{Invocations: []Invocation{}},
+	}
+}
+
+// Transcript returns all the commands recorded until now.
+func (s *Shell) Transcript() Transcript {
+	if s.transcript == nil {
+		s.t.Fatal("transcript is not enabled on shell")
+	}
+	return s.transcript.get()
+}
+
+// transcriber records the transcript of a shell,
+// that may run scripts concurrently.
+type transcriber struct {
+	mutex      sync.Mutex
+	path       string
+	transcript Transcript
+}
+
+// record appends the invocations to the transcript, saving it.
+func (tr *transcriber) record(invocations []Invocation) error {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tr.transcript.Invocations = append(tr.transcript.Invocations, invocations...)
+
+	// WHY: JSON escaping would hide secrets from redaction
+	redacted := Transcript{Invocations: []Invocation{}}
+	for _, invocation := range tr.transcript.Invocations {
+		redacted.Invocations = append(redacted.Invocations, invocation.redacted())
+	}
+	data, err := json.MarshalIndent(redacted, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tr.path, data, 0644)
+}
+
+func (tr *transcriber) get() Transcript {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	return Transcript{
+		Invocations: append([]Invocation{}, tr.transcript.Invocations...),
+	}
+}
+
+// Calls returns the invocations with a command line starting with
+// the given args, like: Calls("az", "vm", "create").
+func (t Transcript) Calls(cmdline ...string) []Invocation {
+	calls := []Invocation{}
+	for _, invocation := range t.Invocations {
+		if hasArgsPrefix(invocation.Args, cmdline) {
+			calls = append(calls, invocation)
+		}
+	}
+	return calls
+}
+
+// Flag returns the value of the given flag on the invocation,
+// supporting the "--flag value" and "--flag=value" forms.
+func (i Invocation) Flag(name string) (string, bool) {
+	for idx, arg := range i.Args {
+		if arg == name && idx+1 < len(i.Args) {
+			return i.Args[idx+1], true
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"="), true
+		}
+	}
+	return "", false
+}
+
+// redacted returns a copy of the invocation with the secrets
+// redacted from all its fields.
+func (i Invocation) redacted() Invocation {
+	res := i
+	res.Script = testlog.Redact(i.Script)
+	res.Dir = testlog.Redact(i.Dir)
+	res.Stdout = testlog.Redact(i.Stdout)
+	res.Stderr = testlog.Redact(i.Stderr)
+	res.Args = []string{}
+	for _, arg := range i.Args {
+		res.Args = append(res.Args, testlog.Redact(arg))
+	}
+	res.Env = map[string]string{}
+	for name, value := range i.Env {
+		res.Env[name] = testlog.Redact(value)
+	}
+	return res
+}
+
+func hasArgsPrefix(args []string, prefix []string) bool {
+	if len(args) < len(prefix) {
+		return false
+	}
+	for i, arg := range prefix {
+		if args[i] != arg {
+			return false
+		}
+	}
+	return true
+}
+
+func isRecordedEnv(name string) bool {
+	for _, secret := range secretEnv {
+		if strings.Contains(name, secret) {
+			return false
+		}
+	}
+	for _, prefix := range recordedEnv {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package nash
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+func TestInvocationRedacted(t *testing.T) {
+	// WHY: JSON escapes &, < and > so the marshalled
+	// secret would not match the secret anymore.
+	secret := `klb&tests<"secret">`
+	testlog.AddSecret(secret)
+
+	invocation := Invocation{
+		Args:   []string{"az", "login", "--password", secret},
+		Dir:    "/tmp",
+		Env:    map[string]string{"AZURE_CLIENT": secret},
+		Stdout: "key: " + secret + "\n",
+		Stderr: "sig=abcdef&se=2017",
+	}
+	data, err := json.Marshal(invocation.redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped, err := json.Marshal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	if strings.Contains(saved, strings.Trim(string(escaped), `"`)) {
+		t.Fatalf("secret saved on transcript: %s", saved)
+	}
+	if strings.Contains(saved, "abcdef") {
+		t.Fatalf("SAS signature saved on transcript: %s", saved)
+	}
+	if invocation.Args[3] != secret {
+		t.Fatal("redacting changed the original invocation")
+	}
+}
+
+func TestTranscriptCalls(t *testing.T) {
+	transcript := Transcript{Invocations: []Invocation{
+		{Args: []string{"az", "vm", "create", "--name", "vm1"}},
+		{Args: []string{"az", "vm", "show", "--name=vm1"}},
+		{Args: []string{"az", "vm", "create", "--name", "vm2"}},
+	}}
+
+	calls := transcript.Calls("az", "vm", "create")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if name, _ := calls[1].Flag("--name"); name != "vm2" {
+		t.Errorf("expected name[vm2], got[%s]", name)
+	}
+	show := transcript.Calls("az", "vm", "show")[0]
+	if name, _ := show.Flag("--name"); name != "vm1" {
+		t.Errorf("expected name[vm1] on --flag=value form, got[%s]", name)
+	}
+	if _, ok := show.Flag("--resource-group"); ok {
+		t.Error("found missing flag")
+	}
+}
@@ -0,0 +1,470 @@
+package nash
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// workerArgFn gets the args of a request, values are base64 encoded
+// since they may have new lines.
+const workerArgFn = `fn klbtests_arg(request, i) {
+	# WHY: the prefix keeps empty values on the list
+	filter <= format(".args[%s].values[] | \"x\" + @base64", $i)
+	encoded <= echo $request | jq -r $filter
+	values = ()
+	if $encoded != "" {
+		lines <= split($encoded, "\n")
+		for line in $lines {
+			value <= echo $line | cut -c2- | base64 -d
+			values <= append($values, $value)
+		}
+	}
+	filter <= format(".args[%s].list", $i)
+	islist <= echo $request | jq -r $filter
+	if $islist == "true" {
+		return $values
+	}
+	return $values[0]
+}
+`
+
+// workerSignature is the jq filter computing the signature
+// of a request, that must match call.signature.
+const workerSignature = `[.module, .function, (.args | length), .results, .nested] | map(tostring) | join(\":\")`
+
+// Responses are written on the file descriptor 3 of the worker,
+// so the output of the klb functions can't be taken as a response.
+const (
+	workerReady = "klb-tests-ready"
+	workerDone  = "klb-tests-done"
+)
+
+// workerStopTimeout is how long a worker has to exit after
+// its stdin is closed before it is killed.
+const workerStopTimeout = 10 * time.Second
+
+// worker is a long lived nash process that logs in once and then
+// calls klb functions, requested as JSON lines with the module, the
+// function and its args, on the same process.
+//
+// Since nash can't call a function given by name the worker script
+// is generated with the calls made so far, a call of another function,
+// or with other number of args or results, restarts the worker. It is
+// restarted on the same HOME, where the az and azure logins are saved,
+// so it does not log in again. If it crashes, like when a function calls
+// exit, or the call context is cancelled it is killed and a new one is
+// started on a new HOME, logging in again, on the next call.
+type worker struct {
+	t      *testing.T
+	logger *testlog.Logger
+	env    []string
+	login  bool
+	// nash is the nash command, replaced by tests
+	nash string
+
+	mutex    sync.Mutex
+	calls    []call
+	sandbox  *sandbox
+	loggedIn bool
+	process  *workerProcess
+}
+
+type workerRequest struct {
+	Module   string      `json:"module"`
+	Function string      `json:"function"`
+	Args     []workerArg `json:"args"`
+	Results  int         `json:"results"`
+	Nested   bool        `json:"nested"`
+}
+
+type workerArg struct {
+	List   bool     `json:"list"`
+	Values []string `json:"values"`
+}
+
+type workerProcess struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses *bufio.Reader
+	pipe      *os.File
+	output    *logWriter
+}
+
+// EnableWorker makes calls run on a worker process that logs in
+// only once, instead of logging in on every call. Calls still run
+// on a new nash process while the shell has stubs, a transcript
+// or a cassette, since the worker commands can't be shimmed.
+// Close must be called when the shell is no longer needed.
+func (s *Shell) EnableWorker() {
+	s.worker = &worker{
+		t:      s.t,
+		logger: s.logger,
+		env:    s.env,
+		login:  !s.offline,
+		nash:   "nash",
+	}
+}
+
+// Close stops the worker, if it is enabled.
+func (s *Shell) Close() {
+	if s.worker == nil {
+		return
+	}
+	s.worker.close()
+}
+
+// signature identifies the calls that run the same
+// statements on the worker, only the args values differ.
+func (c call) signature() string {
+	return fmt.Sprintf("%s:%s:%d:%d:%t", c.module, c.fn, len(c.args), c.results, c.nested)
+}
+
+// call calls the function on the worker process, killing
+// it if the given context is cancelled. Args must be valid.
+func (w *worker) call(ctx context.Context, logger *testlog.Logger, c call) ([][]string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.knows(c) {
+		w.calls = append(w.calls, c)
+		if w.process != nil {
+			logger.Printf("worker: restarting to call %s on %s", c.fn, c.module)
+			w.stop()
+		}
+	}
+	if w.process == nil {
+		err := w.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := json.Marshal(newWorkerRequest(c))
+	if err != nil {
+		return nil, err
+	}
+	resultsdir := w.resultsdir()
+	err = os.RemoveAll(resultsdir)
+	if err == nil {
+		err = os.Mkdir(resultsdir, 0755)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Printf("worker: calling: %s on %s", c.fn, c.module)
+	start := time.Now()
+	err = w.process.call(ctx, request)
+	if err != nil {
+		return nil, w.crashed(ctx, logger, []string{c.module, c.fn}, start, err)
+	}
+	return readResults(resultsdir, c.results)
+}
+
+func newWorkerRequest(c call) workerRequest {
+	request := workerRequest{
+		Module:   c.module,
+		Function: c.fn,
+		Args:     []workerArg{},
+		Results:  c.results,
+		Nested:   c.nested,
+	}
+	for _, arg := range c.args {
+		switch val := arg.(type) {
+		case string:
+			request.Args = append(request.Args, workerArg{Values: []string{val}})
+		case []string:
+			request.Args = append(request.Args, workerArg{List: true, Values: val})
+		}
+	}
+	return request
+}
+
+func (w *worker) knows(c call) bool {
+	for _, known := range w.calls {
+		if known.signature() == c.signature() {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *worker) resultsdir() string {
+	return filepath.Join(w.sandbox.homedir, "results")
+}
+
+// crashed kills the worker after a failed call or login,
+// returning the error with the worker exit status.
+func (w *worker) crashed(
+	ctx context.Context,
+	logger *testlog.Logger,
+	cmdline []string,
+	start time.Time,
+	err error,
+) error {
+	process := w.process
+	w.process = nil
+	if ctx.Err() != nil {
+		logger.Printf("worker: %s, killing it", ctx.Err())
+		process.kill()
+		err = ctx.Err()
+	} else {
+		logger.Printf("worker: %s, stopping it", err)
+		process.stop()
+	}
+	w.removeSandbox()
+
+	status := "killed"
+	exitcode := -1
+	if state := process.cmd.ProcessState; state != nil && ctx.Err() == nil {
+		status = state.String()
+		if waitstatus, ok := state.Sys().(syscall.WaitStatus); ok {
+			exitcode = waitstatus.ExitStatus()
+		}
+	}
+	execerr := newExecError(cmdline, time.Since(start), status, process.output, err)
+	execerr.ExitCode = exitcode
+	return execerr
+}
+
+func (w *worker) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.stop()
+	w.removeSandbox()
+}
+
+// stop stops the worker process, keeping its HOME.
+func (w *worker) stop() {
+	if w.process == nil {
+		return
+	}
+	err := w.process.stop()
+	if err != nil {
+		w.logger.Printf("worker: stopped with error: %s", err)
+	}
+	w.process = nil
+}
+
+func (w *worker) removeSandbox() {
+	if w.sandbox == nil {
+		return
+	}
+	err := w.sandbox.remove()
+	if err != nil {
+		w.t.Errorf("error removing worker sandbox: %s", err)
+	}
+	w.sandbox = nil
+	w.loggedIn = false
+}
+
+// start starts a worker process calling the known calls, waiting
+// for it to log in if it is running on a new HOME.
+func (w *worker) start(ctx context.Context) error {
+	if w.sandbox == nil {
+		sandbox, err := newSandbox(w.logger.Logger, w.env)
+		if err != nil {
+			return err
+		}
+		w.sandbox = sandbox
+	}
+
+	login := w.login && !w.loggedIn
+	w.logger.Printf("worker: starting, login=%t", login)
+	scriptpath := filepath.Join(w.sandbox.homedir, "klb-tests-worker.sh")
+	err := ioutil.WriteFile(scriptpath, []byte(workerScript(w.calls, login, w.resultsdir())), 0755)
+	if err != nil {
+		return err
+	}
+
+	pipe, responses, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	output := newLogWriter(w.logger.Logger)
+	cmd := exec.Command(w.nash, scriptpath)
+	cmd.Env = append(w.sandbox.env, coverageEnv(w.t.Name())...)
+	cmd.Env = append(cmd.Env, "KLB_TESTS_WORKER="+w.sandbox.homedir)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.ExtraFiles = []*os.File{responses}
+	// WHY: a process group allows us to kill the nash children too
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	// WHY: the worker must hold the only write end, so
+	// reading the responses fails when it exits
+	responses.Close()
+	if err != nil {
+		pipe.Close()
+		return err
+	}
+
+	w.process = &workerProcess{
+		cmd:       cmd,
+		stdin:     stdin,
+		responses: bufio.NewReader(pipe),
+		pipe:      pipe,
+		output:    output,
+	}
+	start := time.Now()
+	err = w.process.response(ctx, workerReady)
+	if err != nil {
+		return w.crashed(ctx, w.logger, []string{"worker"}, start, err)
+	}
+	if login {
+		w.loggedIn = true
+		w.logger.Printf("worker: logged in after %s", time.Since(start))
+	}
+	return nil
+}
+
+// workerScript generates the worker script, that calls the given calls
+// when requested, writing the results on files at resultsdir.
+func workerScript(calls []call, login bool, resultsdir string) string {
+	var code bytes.Buffer
+	code.WriteString("#!/usr/bin/env nash\n\n")
+	code.WriteString("# klb tests worker: calls klb functions without logging in again\n\n")
+	modules := []string{}
+	if login {
+		modules = append(modules, "klb/azure/login")
+	}
+	for _, c := range calls {
+		if !contains(modules, c.module) {
+			modules = append(modules, c.module)
+		}
+	}
+	for _, module := range modules {
+		fmt.Fprintf(&code, "import %s\n", module)
+	}
+	code.WriteString("\n")
+
+	code.WriteString(workerArgFn)
+	for i, c := range calls {
+		fmt.Fprintf(&code, "\n# %s\n", c.signature())
+		fmt.Fprintf(&code, "fn klbtests_call%d(request) {\n", i)
+		for j := range c.args {
+			fmt.Fprintf(&code, "\tklbtestsarg%d <= klbtests_arg($request, \"%d\")\n", j, j)
+		}
+		statements := strings.TrimSuffix(callStatements(c, resultsdir), "\n")
+		for _, line := range strings.Split(statements, "\n") {
+			fmt.Fprintf(&code, "\t%s\n", line)
+		}
+		code.WriteString("}\n")
+	}
+	code.WriteString("\n")
+
+	if login {
+		code.WriteString("azure_login()\n\n")
+	}
+	fmt.Fprintf(&code, "echo %q > /dev/fd/3\n\n", workerReady)
+	code.WriteString("for {\n")
+	code.WriteString("\t# WHY: only one request is sent at a time, so head reads just it\n")
+	code.WriteString("\trequest <= head -n 1\n")
+	code.WriteString("\tif $request == \"\" {\n\t\texit(\"0\")\n\t}\n")
+	fmt.Fprintf(&code, "\tsignature <= echo $request | jq -r \"%s\"\n", workerSignature)
+	for i, c := range calls {
+		fmt.Fprintf(&code, "\tif $signature == %s {\n", nashQuote(c.signature()))
+		fmt.Fprintf(&code, "\t\tklbtests_call%d($request)\n", i)
+		code.WriteString("\t}\n")
+	}
+	fmt.Fprintf(&code, "\techo %q > /dev/fd/3\n", workerDone)
+	code.WriteString("}\n")
+	return code.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// call sends the request, waiting for the call to be done.
+func (p *workerProcess) call(ctx context.Context, request []byte) error {
+	_, err := p.stdin.Write(append(request, '\n'))
+	if err != nil {
+		return err
+	}
+	return p.response(ctx, workerDone)
+}
+
+// response waits for the given response, bound to the given context.
+func (p *workerProcess) response(ctx context.Context, want string) error {
+	type result struct {
+		line string
+		err  error
+	}
+	// WHY: buffered channel avoid goroutine leak, the read
+	// fails when the worker is killed
+	done := make(chan result, 1)
+	go func() {
+		line, err := p.responses.ReadString('\n')
+		done <- result{line: line, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if res.err != nil {
+		return fmt.Errorf("reading worker response: %s", res.err)
+	}
+	if strings.TrimSuffix(res.line, "\n") != want {
+		return fmt.Errorf("invalid worker response[%s], expected[%s]", res.line, want)
+	}
+	return nil
+}
+
+// stop closes the worker stdin, so it exits, killing it
+// if it does not exit in time.
+func (p *workerProcess) stop() error {
+	p.stdin.Close()
+
+	// WHY: buffered channel avoid goroutine leak
+	done := make(chan error, 1)
+	go func() {
+		done <- p.cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(workerStopTimeout):
+		syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+		err = <-done
+	}
+	p.output.flush()
+	p.pipe.Close()
+	return err
+}
+
+func (p *workerProcess) kill() {
+	syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+	p.cmd.Wait()
+	p.output.flush()
+	p.pipe.Close()
+}
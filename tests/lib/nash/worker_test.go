@@ -0,0 +1,233 @@
+package nash
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// fakeNash answers the worker requests like the worker script,
+// the results are the args values. It records on the file at
+// FAKE_NASH_LOG when it starts and when it logs in.
+const fakeNash = `#!/bin/sh
+echo start >> "$FAKE_NASH_LOG"
+if grep -q "^azure_login()" "$1"; then
+	echo login >> "$FAKE_NASH_LOG"
+fi
+echo klb-tests-ready >&3
+
+while IFS= read -r request; do
+	function=$(printf '%s\n' "$request" | jq -r .function)
+	case "$function" in
+	crash)
+		echo "crashing"
+		exit 3
+		;;
+	hang)
+		sleep 60
+		;;
+	esac
+	results=$(printf '%s\n' "$request" | jq -r .results)
+	if [ "$results" -gt 0 ]; then
+		{
+			printf 'klb-tests-result\0'
+			printf '%s\n' "$request" | jq -j '.args[].values[] | . + "\u0000"'
+		} > "$KLB_TESTS_WORKER/results/result0"
+	fi
+	echo klb-tests-done >&3
+done
+`
+
+func TestWorkerCalls(t *testing.T) {
+	w, fakelog, teardown := newFakeWorker(t)
+	defer teardown()
+
+	got := workerCall(t, w, call{module: "klb/azure/vm", fn: "echo", args: []interface{}{"a b", []string{"", "with \"quotes\"\nand lines"}}, results: 1})
+	want := [][]string{{"a b", "", "with \"quotes\"\nand lines"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected[%q], got[%q]", want, got)
+	}
+
+	got = workerCall(t, w, call{module: "klb/azure/vm", fn: "echo", args: []interface{}{"c", []string{}}, results: 1})
+	want = [][]string{{"c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected[%q], got[%q]", want, got)
+	}
+	assertFakeLog(t, fakelog, "start", "login")
+
+	workerCall(t, w, call{module: "klb/azure/nic", fn: "other", args: []interface{}{"c"}})
+	assertFakeLog(t, fakelog, "start", "login", "start")
+}
+
+func TestWorkerRestartsAfterCrash(t *testing.T) {
+	w, fakelog, teardown := newFakeWorker(t)
+	defer teardown()
+
+	_, err := w.call(context.Background(), w.logger, call{module: "klb/azure/vm", fn: "crash"})
+	execerr, ok := err.(*ExecError)
+	if !ok {
+		t.Fatalf("expected ExecError, got[%v]", err)
+	}
+	if execerr.ExitCode != 3 {
+		t.Errorf("expected exit code[3], got[%d]", execerr.ExitCode)
+	}
+	if len(execerr.Output) == 0 || execerr.Output[len(execerr.Output)-1] != "crashing" {
+		t.Errorf("expected worker output on the error, got[%q]", execerr.Output)
+	}
+
+	got := workerCall(t, w, call{module: "klb/azure/vm", fn: "echo", args: []interface{}{"a"}, results: 1})
+	if !reflect.DeepEqual(got, [][]string{{"a"}}) {
+		t.Fatalf("expected[[a]], got[%q]", got)
+	}
+	assertFakeLog(t, fakelog, "start", "login", "start", "login")
+}
+
+func TestWorkerCallCancelled(t *testing.T) {
+	w, fakelog, teardown := newFakeWorker(t)
+	defer teardown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := w.call(ctx, w.logger, call{module: "klb/azure/vm", fn: "hang"})
+	if err == nil {
+		t.Fatal("expected cancelled call to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("cancelled call returned after %s", elapsed)
+	}
+	if execerr, ok := err.(*ExecError); !ok || execerr.Err != context.DeadlineExceeded {
+		t.Fatalf("expected ExecError caused by the deadline, got[%v]", err)
+	}
+
+	workerCall(t, w, call{module: "klb/azure/vm", fn: "echo"})
+	assertFakeLog(t, fakelog, "start", "login", "start", "login")
+}
+
+func TestWorkerScript(t *testing.T) {
+	calls := []call{
+		{module: "klb/azure/vm", fn: "azure_vm_create", args: []interface{}{"vm", []string{"--size", "A1"}}},
+		{module: "klb/azure/vm", fn: "azure_vm_get_id", args: []interface{}{"vm"}, results: 1},
+	}
+
+	type TestCase struct {
+		name     string
+		login    bool
+		want     []string
+		unwanted []string
+	}
+
+	tests := []TestCase{
+		{
+			name:  "Login",
+			login: true,
+			want: []string{
+				"import klb/azure/login\nimport klb/azure/vm\n\n",
+				"fn klbtests_call0(request) {\n" +
+					"\tklbtestsarg0 <= klbtests_arg($request, \"0\")\n" +
+					"\tklbtestsarg1 <= klbtests_arg($request, \"1\")\n" +
+					"\tazure_vm_create($klbtestsarg0, $klbtestsarg1)\n}\n",
+				"\tklbtestsres0 <= azure_vm_get_id($klbtestsarg0)\n" +
+					"\tprintf \"%s\\\\0\" \"klb-tests-result\" $klbtestsres0 > \"/results/result0\"\n}\n",
+				"azure_login()\n",
+				"\tif $signature == \"klb/azure/vm:azure_vm_create:2:0:false\" {\n\t\tklbtests_call0($request)\n\t}\n",
+				"\tif $signature == \"klb/azure/vm:azure_vm_get_id:1:1:false\" {\n\t\tklbtests_call1($request)\n\t}\n",
+			},
+		},
+		{
+			name:     "LoggedIn",
+			want:     []string{"\n\nimport klb/azure/vm\n\n"},
+			unwanted: []string{"klb/azure/login", "azure_login()"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script := workerScript(calls, test.login, "/results")
+			for _, want := range test.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("expected[%s] on script:\n%s", want, script)
+				}
+			}
+			for _, unwanted := range test.unwanted {
+				if strings.Contains(script, unwanted) {
+					t.Errorf("unexpected[%s] on script:\n%s", unwanted, script)
+				}
+			}
+		})
+	}
+}
+
+func TestCallSignature(t *testing.T) {
+	// WHY: the worker script computes the same signature with jq
+	request := `{"module":"klb/azure/vm","function":"azure_vm_create","args":[{"list":false,"values":["vm"]}],"results":2,"nested":true}`
+	c := call{module: "klb/azure/vm", fn: "azure_vm_create", args: []interface{}{"vm"}, results: 2, nested: true}
+	want := "klb/azure/vm:azure_vm_create:1:2:true"
+	if c.signature() != want {
+		t.Fatalf("expected[%s], got[%s]", want, c.signature())
+	}
+	got := jq(t, request, strings.Replace(workerSignature, `\"`, `"`, -1))
+	if got != want {
+		t.Fatalf("expected jq signature[%s], got[%s]", want, got)
+	}
+}
+
+func newFakeWorker(t *testing.T) (*worker, string, func()) {
+	dir, err := ioutil.TempDir("", "klb-tests-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nashpath := filepath.Join(dir, "nash")
+	writeScript(t, nashpath, fakeNash)
+	fakelog := filepath.Join(dir, "fake.log")
+
+	w := &worker{
+		t:      t,
+		logger: testlog.Wrap(log.New(ioutil.Discard, "", 0)),
+		env:    []string{"FAKE_NASH_LOG=" + fakelog},
+		login:  true,
+		nash:   nashpath,
+	}
+	return w, fakelog, func() {
+		w.close()
+		os.RemoveAll(dir)
+	}
+}
+
+func workerCall(t *testing.T, w *worker, c call) [][]string {
+	res, err := w.call(context.Background(), w.logger, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func assertFakeLog(t *testing.T, fakelog string, want ...string) {
+	data, err := ioutil.ReadFile(fakelog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Fields(string(data))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected fake nash log[%q], got[%q]", want, got)
+	}
+}
+
+func jq(t *testing.T, input string, filter string) string {
+	cmd := exec.Command("jq", "-r", filter)
+	cmd.Stdin = strings.NewReader(input)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("error[%s] running jq %s", err, filter)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
@@ -0,0 +1,149 @@
+package plan
+
+import "strings"
+
+// Action is what an operation does with a resource.
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	Read   Action = "read"
+)
+
+// Op is an operation performed by an az/azure command.
+type Op struct {
+	Action        Action
+	ResourceGroup string
+	ResourceType  string
+	Name          string
+	Args          []string
+}
+
+// verbs maps the last word of az/azure subcommands to actions,
+// unknown verbs are handled as updates since it is safer to
+// assume they change something.
+var verbs = map[string]Action{
+	"create":         Create,
+	"add":            Create,
+	"attach":         Create,
+	"upload":         Create,
+	"upload-batch":   Create,
+	"copy":           Create,
+	"set":            Update,
+	"update":         Update,
+	"start":          Update,
+	"stop":           Update,
+	"restart":        Update,
+	"deallocate":     Update,
+	"register":       Update,
+	"grant-access":   Update,
+	"revoke-access":  Update,
+	"delete":         Delete,
+	"remove":         Delete,
+	"detach":         Delete,
+	"show":           Read,
+	"list":           Read,
+	"exists":         Read,
+	"download":       Read,
+	"download-batch": Read,
+	"version":        Read,
+}
+
+// session are subcommands that only change the local
+// cli session, they are handled as reads.
+var session = []string{"login", "logout", "account", "config", "telemetry", "cloud"}
+
+// parseOp parses the op performed by the az/azure command with
+// the given args, args includes the command name.
+func parseOp(args []string) Op {
+	op := Op{Args: args}
+
+	words, positional, flags := splitArgs(args[1:])
+	if isSession(words) {
+		op.Action = Read
+		op.ResourceType = strings.Join(words, " ")
+		return op
+	}
+
+	if len(words) > 0 {
+		verb := words[len(words)-1]
+		words = words[:len(words)-1]
+		action, ok := verbs[verb]
+		if !ok && strings.HasPrefix(verb, "list-") {
+			action, ok = Read, true
+		}
+		if !ok {
+			action = Update
+		}
+		op.Action = action
+	}
+	op.ResourceType = strings.Join(words, " ")
+
+	op.Name = flag(flags, "--name", "-n", "--ids")
+	if op.Name == "" && len(positional) > 0 {
+		op.Name = positional[len(positional)-1]
+	}
+	op.ResourceGroup = flag(flags, "--resource-group", "-g")
+	if op.ResourceType == "group" {
+		op.ResourceGroup = op.Name
+	}
+	return op
+}
+
+// splitArgs splits the subcommand words, the positional args
+// (azure cli 1.0 ones) and the flags with their values.
+func splitArgs(args []string) ([]string, []string, map[string]string) {
+	words := []string{}
+	positional := []string{}
+	flags := map[string]string{}
+
+	i := 0
+	for ; i < len(args) && !strings.HasPrefix(args[i], "-"); i++ {
+		words = append(words, args[i])
+		if _, ok := verbs[args[i]]; ok {
+			i++
+			break
+		}
+	}
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+			continue
+		}
+		if parsed := strings.SplitN(arg, "=", 2); len(parsed) == 2 {
+			flags[parsed[0]] = parsed[1]
+			continue
+		}
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			flags[arg] = args[i+1]
+			i++
+			continue
+		}
+		flags[arg] = ""
+	}
+	return words, positional, flags
+}
+
+func flag(flags map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := flags[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func isSession(words []string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	for _, name := range session {
+		if words[0] == name {
+			return true
+		}
+	}
+	return false
+}
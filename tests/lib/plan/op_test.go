@@ -0,0 +1,148 @@
+package plan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOp(t *testing.T) {
+	type TestCase struct {
+		name string
+		args string
+		want Op
+	}
+
+	tests := []TestCase{
+		{
+			name: "Create",
+			args: "az vm create --resource-group rg --name vm --size Standard_DS4_v2",
+			want: Op{Action: Create, ResourceGroup: "rg", ResourceType: "vm", Name: "vm"},
+		},
+		{
+			name: "ShortFlags",
+			args: "az network nic delete -g rg -n nic",
+			want: Op{Action: Delete, ResourceGroup: "rg", ResourceType: "network nic", Name: "nic"},
+		},
+		{
+			name: "FlagsWithEquals",
+			args: "az disk update --resource-group=rg --name=disk",
+			want: Op{Action: Update, ResourceGroup: "rg", ResourceType: "disk", Name: "disk"},
+		},
+		{
+			name: "NameFromIDs",
+			args: "az vm show --ids /subscriptions/sub/vm",
+			want: Op{Action: Read, ResourceType: "vm", Name: "/subscriptions/sub/vm"},
+		},
+		{
+			name: "Group",
+			args: "az group create --name rg --location eastus2",
+			want: Op{Action: Create, ResourceGroup: "rg", ResourceType: "group", Name: "rg"},
+		},
+		{
+			name: "ListPrefixIsRead",
+			args: "az vm list-sizes --location eastus2",
+			want: Op{Action: Read, ResourceType: "vm"},
+		},
+		{
+			name: "UnknownVerbIsUpdate",
+			args: "az vm resize -g rg -n vm --size Standard_DS2_v2",
+			want: Op{Action: Update, ResourceGroup: "rg", ResourceType: "vm", Name: "vm"},
+		},
+		{
+			name: "PositionalName",
+			args: "azure vm delete klb-vm -q",
+			want: Op{Action: Delete, ResourceType: "vm", Name: "klb-vm"},
+		},
+		{
+			name: "Session",
+			args: "az account set --subscription sub",
+			want: Op{Action: Read, ResourceType: "account set"},
+		},
+		{
+			name: "Login",
+			args: "azure login --service-principal -u id -p secret",
+			want: Op{Action: Read, ResourceType: "login"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := strings.Fields(test.args)
+			test.want.Args = args
+			got := parseOp(args)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected[%+v], got[%+v]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	type TestCase struct {
+		name       string
+		args       string
+		words      []string
+		positional []string
+		flags      map[string]string
+	}
+
+	tests := []TestCase{
+		{
+			name:       "Empty",
+			words:      []string{},
+			positional: []string{},
+			flags:      map[string]string{},
+		},
+		{
+			name:       "WordsAndFlags",
+			args:       "network vnet create --name vnet --address-prefixes 10.0.0.0/16",
+			words:      []string{"network", "vnet", "create"},
+			positional: []string{},
+			flags:      map[string]string{"--name": "vnet", "--address-prefixes": "10.0.0.0/16"},
+		},
+		{
+			name:       "WordsEndOnVerb",
+			args:       "group create rg eastus2",
+			words:      []string{"group", "create"},
+			positional: []string{"rg", "eastus2"},
+			flags:      map[string]string{},
+		},
+		{
+			name:       "FlagWithoutValue",
+			args:       "group delete --yes --name rg",
+			words:      []string{"group", "delete"},
+			positional: []string{},
+			flags:      map[string]string{"--yes": "", "--name": "rg"},
+		},
+		{
+			name:       "LastFlagWithoutValue",
+			args:       "group delete --name rg --no-wait",
+			words:      []string{"group", "delete"},
+			positional: []string{},
+			flags:      map[string]string{"--name": "rg", "--no-wait": ""},
+		},
+		{
+			name:       "FlagWithEquals",
+			args:       "vm create --name=vm --size=big extra",
+			words:      []string{"vm", "create"},
+			positional: []string{"extra"},
+			flags:      map[string]string{"--name": "vm", "--size": "big"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			words, positional, flags := splitArgs(strings.Fields(test.args))
+			if !reflect.DeepEqual(words, test.words) {
+				t.Errorf("expected words[%q], got[%q]", test.words, words)
+			}
+			if !reflect.DeepEqual(positional, test.positional) {
+				t.Errorf("expected positional[%q], got[%q]", test.positional, positional)
+			}
+			if !reflect.DeepEqual(flags, test.flags) {
+				t.Errorf("expected flags[%q], got[%q]", test.flags, flags)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package plan runs klb scripts with the mutating az and azure
+// commands stubbed, reporting what the scripts would do on the
+// cloud without needing credentials.
+package plan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+)
+
+// Plan has the ops a script would perform.
+type Plan struct {
+	// Ops are the create/update/delete ops, on the
+	// order they would be performed.
+	Ops []Op
+	// Unanswered are the reads the state had no answer for,
+	// they were answered as if the resource does not exist.
+	Unanswered []Op
+}
+
+// credentials are set with fake values when not provided,
+// since klb requires them to login.
+var credentials = []string{
+	"AZURE_SUBSCRIPTION_ID",
+	"AZURE_SUBSCRIPTION_NAME",
+	"AZURE_TENANT_ID",
+	"AZURE_CLIENT_ID",
+	"AZURE_CLIENT_SECRET",
+	"AZURE_SERVICE_PRINCIPAL",
+}
+
+const notFound = "ResourceNotFound: klb plan: not found on the state\n"
+
+// Run runs the script with all az and azure commands stubbed,
+// returning the ops it would perform. Reads are answered by
+// the given state, and mutating commands by the state too if
+// it has a response for them, succeeding with no output otherwise.
+//
+// The plan is returned even when the script fails, since the
+// ops performed before the failure are useful to understand it.
+func Run(
+	ctx context.Context,
+	logger *log.Logger,
+	env []string,
+	state *State,
+	scriptpath string,
+	args ...string,
+) (*Plan, error) {
+	plan := &Plan{}
+	stub := func(args []string) (nash.Interaction, bool) {
+		op := parseOp(args)
+		response, ok := state.answer(args)
+		if op.Action != Read {
+			plan.Ops = append(plan.Ops, op)
+			if !ok {
+				response = nash.Interaction{Args: args}
+			}
+			return response, true
+		}
+		if ok || isSession(strings.Fields(op.ResourceType)) {
+			return response, true
+		}
+		plan.Unanswered = append(plan.Unanswered, op)
+		return nash.Interaction{
+			Args:     args,
+			Stderr:   notFound,
+			ExitCode: 3,
+		}, true
+	}
+
+	_, err := nash.RunStubbed(
+		ctx,
+		logger,
+		withCredentials(env),
+		map[string]nash.Stub{
+			"az":    stub,
+			"azure": stub,
+		},
+		scriptpath,
+		args...,
+	)
+	return plan, err
+}
+
+// String formats the plan with the ops grouped by resource
+// group and resource type, on the order they first appear.
+func (p *Plan) String() string {
+	var out bytes.Buffer
+
+	for _, group := range groupBy(p.Ops, func(op Op) string { return op.ResourceGroup }) {
+		if group[0].ResourceGroup == "" {
+			fmt.Fprintf(&out, "no resource group:\n")
+		} else {
+			fmt.Fprintf(&out, "resource group %s:\n", group[0].ResourceGroup)
+		}
+		for _, ops := range groupBy(group, func(op Op) string { return op.ResourceType }) {
+			fmt.Fprintf(&out, "\t%s:\n", ops[0].ResourceType)
+			for _, op := range ops {
+				fmt.Fprintf(&out, "\t\t%s %s\n", op.Action, op.Name)
+			}
+		}
+	}
+
+	if len(p.Unanswered) > 0 {
+		fmt.Fprintf(&out, "\nreads answered as not found:\n")
+		for _, op := range p.Unanswered {
+			fmt.Fprintf(&out, "\t%s\n", strings.Join(op.Args, " "))
+		}
+	}
+
+	fmt.Fprintf(
+		&out,
+		"\nplan: %d to create, %d to update, %d to delete\n",
+		p.count(Create),
+		p.count(Update),
+		p.count(Delete),
+	)
+	return out.String()
+}
+
+func (p *Plan) count(action Action) int {
+	count := 0
+	for _, op := range p.Ops {
+		if op.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// groupBy groups the ops by the given key, keeping
+// the order the keys and the ops first appear.
+func groupBy(ops []Op, key func(Op) string) [][]Op {
+	groups := [][]Op{}
+	index := map[string]int{}
+	for _, op := range ops {
+		k := key(op)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, []Op{})
+		}
+		groups[i] = append(groups[i], op)
+	}
+	return groups
+}
+
+func withCredentials(env []string) []string {
+	res := append([]string{}, env...)
+	for _, name := range credentials {
+		if !hasVar(env, name) {
+			res = append(res, name+"=klb-plan-"+strings.ToLower(name))
+		}
+	}
+	return res
+}
+
+func hasVar(env []string, name string) bool {
+	for _, envvar := range env {
+		if strings.HasPrefix(envvar, name+"=") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanString(t *testing.T) {
+	type TestCase struct {
+		name string
+		plan Plan
+		want string
+	}
+
+	tests := []TestCase{
+		{
+			name: "Empty",
+			want: "\nplan: 0 to create, 0 to update, 0 to delete\n",
+		},
+		{
+			name: "GroupedOnFirstAppearance",
+			plan: Plan{
+				Ops: []Op{
+					{Action: Create, ResourceGroup: "rg", ResourceType: "group", Name: "rg"},
+					{Action: Create, ResourceGroup: "rg", ResourceType: "network vnet", Name: "vnet"},
+					{Action: Create, ResourceGroup: "other", ResourceType: "vm", Name: "vm"},
+					{Action: Update, ResourceGroup: "rg", ResourceType: "vm", Name: "vm"},
+					{Action: Create, ResourceGroup: "rg", ResourceType: "network vnet", Name: "vnet2"},
+					{Action: Delete, ResourceType: "ad sp", Name: "sp"},
+				},
+			},
+			want: `resource group rg:
+	group:
+		create rg
+	network vnet:
+		create vnet
+		create vnet2
+	vm:
+		update vm
+resource group other:
+	vm:
+		create vm
+no resource group:
+	ad sp:
+		delete sp
+
+plan: 4 to create, 1 to update, 1 to delete
+`,
+		},
+		{
+			name: "Unanswered",
+			plan: Plan{
+				Ops: []Op{{Action: Delete, ResourceGroup: "rg", ResourceType: "vm", Name: "vm"}},
+				Unanswered: []Op{
+					{Action: Read, Args: []string{"az", "vm", "show", "--name", "vm"}},
+				},
+			},
+			want: `resource group rg:
+	vm:
+		delete vm
+
+reads answered as not found:
+	az vm show --name vm
+
+plan: 0 to create, 0 to update, 1 to delete
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.plan.String()
+			if got != test.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestWithCredentials(t *testing.T) {
+	env := []string{"AZURE_SUBSCRIPTION_ID=sub", "AZURE_CLIENT_ID_OTHER=other"}
+	got := withCredentials(env)
+
+	want := append(env, []string{
+		"AZURE_SUBSCRIPTION_NAME=klb-plan-azure_subscription_name",
+		"AZURE_TENANT_ID=klb-plan-azure_tenant_id",
+		"AZURE_CLIENT_ID=klb-plan-azure_client_id",
+		"AZURE_CLIENT_SECRET=klb-plan-azure_client_secret",
+		"AZURE_SERVICE_PRINCIPAL=klb-plan-azure_service_principal",
+	}...)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected[%s], got[%s]", strings.Join(want, " "), strings.Join(got, " "))
+	}
+	if len(env) != 2 {
+		t.Fatalf("env changed: %q", env)
+	}
+}
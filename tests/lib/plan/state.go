@@ -0,0 +1,68 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+)
+
+// State is the canned state of the cloud used to answer
+// the commands invoked by the planned scripts.
+type State struct {
+	Responses []nash.Interaction `json:"responses"`
+}
+
+// LoadState loads the state from a JSON file, like:
+//
+//	{
+//		"responses": [
+//			{
+//				"args": ["az", "group", "show", "--name", "klb-examples-vm"],
+//				"stdout": "{\"name\": \"klb-examples-vm\"}",
+//				"exit_code": 0
+//			}
+//		]
+//	}
+//
+// An empty path returns an empty state.
+func LoadState(path string) (*State, error) {
+	state := &State{}
+	if path == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state[%s]: %s", path, err)
+	}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state[%s]: %s", path, err)
+	}
+	return state, nil
+}
+
+// answer returns the first response whose args
+// are a prefix of the given args.
+func (s *State) answer(args []string) (nash.Interaction, bool) {
+	for _, response := range s.Responses {
+		if isPrefix(response.Args, args) {
+			response.Args = args
+			return response, true
+		}
+	}
+	return nash.Interaction{}, false
+}
+
+func isPrefix(prefix []string, args []string) bool {
+	if len(prefix) > len(args) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
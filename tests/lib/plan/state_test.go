@@ -0,0 +1,143 @@
+package plan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+)
+
+func TestStateAnswer(t *testing.T) {
+	state := &State{
+		Responses: []nash.Interaction{
+			{Args: []string{"az", "group", "show", "--name", "rg"}, Stdout: `{"name": "rg"}`},
+			{Args: []string{"az", "group", "show"}, Stderr: "not found", ExitCode: 3},
+			{Args: []string{"az", "vm", "list"}, Stdout: "[]"},
+		},
+	}
+
+	type TestCase struct {
+		name string
+		args string
+		want nash.Interaction
+		ok   bool
+	}
+
+	tests := []TestCase{
+		{
+			name: "Exact",
+			args: "az vm list",
+			want: nash.Interaction{Stdout: "[]"},
+			ok:   true,
+		},
+		{
+			name: "Prefix",
+			args: "az group show --name rg --output json",
+			want: nash.Interaction{Stdout: `{"name": "rg"}`},
+			ok:   true,
+		},
+		{
+			name: "FirstPrefixWins",
+			args: "az group show --name other",
+			want: nash.Interaction{Stderr: "not found", ExitCode: 3},
+			ok:   true,
+		},
+		{
+			name: "LongerThanArgs",
+			args: "az group",
+		},
+		{
+			name: "NoAnswer",
+			args: "az vm show --name vm",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := strings.Fields(test.args)
+			got, ok := state.answer(args)
+			if ok != test.ok {
+				t.Fatalf("expected answered[%t], got[%t]", test.ok, ok)
+			}
+			if ok {
+				test.want.Args = args
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected[%+v], got[%+v]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klb-tests-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid.json")
+	err = ioutil.WriteFile(valid, []byte(`{
+		"responses": [
+			{"args": ["az", "group", "show"], "stdout": "{}", "exit_code": 3}
+		]
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	err = ioutil.WriteFile(invalid, []byte(`{"responses": `), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestCase struct {
+		name    string
+		path    string
+		want    []nash.Interaction
+		wantErr bool
+	}
+
+	tests := []TestCase{
+		{
+			name: "NoPath",
+		},
+		{
+			name: "Valid",
+			path: valid,
+			want: []nash.Interaction{{Args: []string{"az", "group", "show"}, Stdout: "{}", ExitCode: 3}},
+		},
+		{
+			name:    "Invalid",
+			path:    invalid,
+			wantErr: true,
+		},
+		{
+			name:    "Missing",
+			path:    filepath.Join(dir, "missing.json"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, err := LoadState(test.path)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(state.Responses, test.want) {
+				t.Errorf("expected[%+v], got[%+v]", test.want, state.Responses)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+// Package progress follows the logs of running fixture tests,
+// tracking what each one is doing right now.
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+// errorMarker is logged by the retrier before each error.
+const errorMarker = "got error: "
+
+// finishedMarker is logged by the fixture when the test function returns.
+const finishedMarker = "fixture: finished"
+
+// Test is the progress of a test.
+type Test struct {
+	Name     string
+	ResGroup string
+	Phase    string
+	Script   string
+	Op       string
+	// Retries of the current operation.
+	Retries   int
+	LastError string
+	Start     time.Time
+	Last      time.Time
+	Finished  bool
+	Failed    bool
+}
+
+// Activity describes what the test is doing, like
+// "running create_vm.sh" or "asserting VM.AssertExists:vm".
+func (t *Test) Activity() string {
+	if t.Finished {
+		if t.Failed {
+			return "finished, failed"
+		}
+		return "finished"
+	}
+	switch t.Phase {
+	case testlog.PhaseSetup:
+		return "creating resgroup"
+	case testlog.PhaseScript:
+		return "running " + filepath.Base(t.Script)
+	case testlog.PhaseAssert:
+		return "asserting " + t.Op
+	case testlog.PhaseTeardown:
+		return "teardown"
+	}
+	return t.Phase
+}
+
+func (t *Test) update(entry testlog.Entry) {
+	if t.Start.IsZero() {
+		t.Start = entry.Time
+	}
+	t.Last = entry.Time
+	if entry.Test != "" {
+		t.Name = entry.Test
+	}
+	if entry.ResGroup != "" {
+		t.ResGroup = entry.ResGroup
+	}
+	if entry.Phase != "" && !t.Finished {
+		t.Phase = entry.Phase
+		t.Script = entry.Script
+	}
+	if entry.Op != "" {
+		if entry.Op != t.Op {
+			t.Retries = 0
+		}
+		t.Op = entry.Op
+		if entry.Attempt > 1 {
+			t.Retries = entry.Attempt - 1
+		}
+	}
+	if i := strings.Index(entry.Msg, errorMarker); i >= 0 {
+		t.LastError = entry.Msg[i+len(errorMarker):]
+	}
+	if strings.HasPrefix(entry.Msg, finishedMarker) {
+		t.Finished = true
+		t.Failed = strings.Contains(entry.Msg, "failed=true")
+	}
+}
+
+// file is a log file being followed.
+type file struct {
+	offset  int64
+	partial []byte
+	test    *Test
+}
+
+// Tracker follows all log files of a logs dir.
+type Tracker struct {
+	dir      string
+	resolved string
+	files    map[string]*file
+}
+
+// NewTracker creates a tracker of the logs on the given dir,
+// like ./tests/azure/testdata/logs/latest.
+func NewTracker(dir string) *Tracker {
+	return &Tracker{dir: dir, files: map[string]*file{}}
+}
+
+// Update reads everything written to the logs since the last update.
+// While the logs dir does not exist, like before the first test of a
+// run saves its logs, nothing is read and the next update tries again.
+func (tr *Tracker) Update() error {
+	resolved, err := filepath.EvalSymlinks(tr.dir)
+	if os.IsNotExist(err) {
+		tr.resolved = ""
+		tr.files = map[string]*file{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resolved != tr.resolved {
+		// WHY: the latest link now points to a new run
+		tr.resolved = resolved
+		tr.files = map[string]*file{}
+	}
+	paths, err := filepath.Glob(filepath.Join(resolved, "*.logs"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		f, ok := tr.files[path]
+		if !ok {
+			f = &file{test: &Test{}}
+			tr.files[path] = f
+		}
+		err := f.read(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *file) read(path string) error {
+	osfile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer osfile.Close()
+
+	info, err := osfile.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < f.offset {
+		// WHY: the file was created again by a new run
+		*f = file{test: &Test{}}
+	}
+	_, err = osfile.Seek(f.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(osfile)
+	if err != nil {
+		return err
+	}
+	f.offset += int64(len(data))
+
+	data = append(f.partial, data...)
+	lines := bytes.Split(data, []byte("\n"))
+	f.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		var entry testlog.Entry
+		if json.Unmarshal(line, &entry) != nil {
+			// WHY: logs with the console format are ignored
+			continue
+		}
+		f.test.update(entry)
+	}
+	return nil
+}
+
+// Waiting tells if the last update is still waiting for the logs dir.
+func (tr *Tracker) Waiting() bool {
+	return tr.resolved == ""
+}
+
+// Tests returns the progress of all tests, sorted by name.
+func (tr *Tracker) Tests() []Test {
+	tests := []Test{}
+	for path, f := range tr.files {
+		test := *f.test
+		if test.Name == "" {
+			test.Name = strings.TrimSuffix(filepath.Base(path), ".logs")
+		}
+		tests = append(tests, test)
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Name < tests[j].Name
+	})
+	return tests
+}
+
+// maxErrorSize truncates errors so each test fits on a line.
+const maxErrorSize = 80
+
+// Render writes a table with the progress of all tests,
+// using now to calculate how long they are running.
+func Render(w io.Writer, tests []Test, now time.Time) error {
+	running := 0
+	for _, test := range tests {
+		if !test.Finished {
+			running++
+		}
+	}
+	fmt.Fprintf(w, "%s: %d tests, %d running\n\n", now.Format("15:04:05"), len(tests), running)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "test\tactivity\telapsed\tretries\tlast error")
+	for _, test := range tests {
+		end := now
+		if test.Finished {
+			end = test.Last
+		}
+		elapsed := time.Duration(0)
+		if !test.Start.IsZero() {
+			elapsed = end.Sub(test.Start).Round(time.Second)
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%d\t%s\n",
+			test.Name,
+			test.Activity(),
+			elapsed,
+			test.Retries,
+			truncate(firstLine(test.LastError), maxErrorSize),
+		)
+	}
+	return tw.Flush()
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(s, "\n", 2)[0]
+}
+
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	return s[:size-3] + "..."
+}
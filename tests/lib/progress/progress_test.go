@@ -0,0 +1,171 @@
+package progress
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+func TestUpdate(t *testing.T) {
+	type TestCase struct {
+		name     string
+		entries  []testlog.Entry
+		activity string
+		retries  int
+		lastErr  string
+	}
+
+	tests := []TestCase{
+		{
+			name: "Setup",
+			entries: []testlog.Entry{
+				{Fields: testlog.Fields{Phase: testlog.PhaseSetup}, Msg: "fixture: setting up resgroup"},
+			},
+			activity: "creating resgroup",
+		},
+		{
+			name: "Script",
+			entries: []testlog.Entry{
+				{Fields: testlog.Fields{Phase: testlog.PhaseSetup}, Msg: "fixture: calling test function"},
+				{Fields: testlog.Fields{Phase: testlog.PhaseScript, Script: "./testdata/create_vm.sh"}, Msg: "running"},
+			},
+			activity: "running create_vm.sh",
+		},
+		{
+			name: "AssertRetried",
+			entries: []testlog.Entry{
+				{
+					Fields: testlog.Fields{Phase: testlog.PhaseAssert, Op: "VM.AssertExists:vm", Attempt: 1},
+					Msg:    "got error: StatusCode=404",
+				},
+				{
+					Fields: testlog.Fields{Phase: testlog.PhaseAssert, Op: "VM.AssertExists:vm", Attempt: 3},
+					Msg:    "got error: StatusCode=429\ntoo many requests",
+				},
+			},
+			activity: "asserting VM.AssertExists:vm",
+			retries:  2,
+			lastErr:  "StatusCode=429\ntoo many requests",
+		},
+		{
+			name: "NewOperation",
+			entries: []testlog.Entry{
+				{Fields: testlog.Fields{Phase: testlog.PhaseAssert, Op: "VM.AssertExists:vm", Attempt: 3}},
+				{Fields: testlog.Fields{Phase: testlog.PhaseAssert, Op: "Nic.AssertExists:nic", Attempt: 1}},
+			},
+			activity: "asserting Nic.AssertExists:nic",
+			retries:  0,
+		},
+		{
+			name: "Finished",
+			entries: []testlog.Entry{
+				{Fields: testlog.Fields{Phase: testlog.PhaseTeardown}, Msg: "fixture: finished, failed=false, waited 0s on the rate limit"},
+				{Fields: testlog.Fields{Phase: testlog.PhaseTeardown}, Msg: "deleting resgroup"},
+			},
+			activity: "finished",
+		},
+		{
+			name: "FinishedFailed",
+			entries: []testlog.Entry{
+				{Fields: testlog.Fields{Phase: testlog.PhaseTeardown}, Msg: "fixture: finished, failed=true, waited 0s on the rate limit"},
+			},
+			activity: "finished, failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			progress := &Test{}
+			for _, entry := range test.entries {
+				progress.update(entry)
+			}
+			if progress.Activity() != test.activity {
+				t.Errorf("expected activity[%s], got[%s]", test.activity, progress.Activity())
+			}
+			if progress.Retries != test.retries {
+				t.Errorf("expected retries[%d], got[%d]", test.retries, progress.Retries)
+			}
+			if progress.LastError != test.lastErr {
+				t.Errorf("expected last error[%s], got[%s]", test.lastErr, progress.LastError)
+			}
+		})
+	}
+}
+
+func TestTrackerWaitsForLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klb-tests-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	latest := filepath.Join(dir, "latest")
+	tracker := NewTracker(latest)
+	err = tracker.Update()
+	if err != nil {
+		t.Fatalf("expected no error before the logs exist, got: %s", err)
+	}
+	if !tracker.Waiting() || len(tracker.Tests()) != 0 {
+		t.Fatalf("expected tracker waiting without tests, got: %+v", tracker.Tests())
+	}
+
+	rundir := filepath.Join(dir, "20170102-150405")
+	err = os.Mkdir(rundir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(rundir, latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logpath := filepath.Join(rundir, "VMCreation.logs")
+	entry, err := json.Marshal(testlog.Entry{
+		Time:   time.Now(),
+		Fields: testlog.Fields{Test: "TestVM/VMCreation", Phase: testlog.PhaseSetup},
+		Msg:    "fixture: setting up resgroup",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// WHY: the last line is still being written
+	err = ioutil.WriteFile(logpath, append(entry, []byte("\n{\"msg\":")...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tracker.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Waiting() {
+		t.Fatal("expected tracker following the logs")
+	}
+	tests := tracker.Tests()
+	if len(tests) != 1 || tests[0].Name != "TestVM/VMCreation" || tests[0].Activity() != "creating resgroup" {
+		t.Fatalf("unexpected progress: %+v", tests)
+	}
+
+	f, err := os.OpenFile(logpath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("\"fixture: finished, failed=false\"}\n")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tracker.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests = tracker.Tests()
+	if len(tests) != 1 || !tests[0].Finished {
+		t.Fatalf("expected finished test, got: %+v", tests)
+	}
+}
@@ -0,0 +1,221 @@
+// Package ratelimit shares the Azure subscription request quota
+// between all tests running in parallel, making them wait instead
+// of failing with throttling errors.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// Kind is the kind of request, Azure has a quota for each one.
+type Kind int
+
+const (
+	Read Kind = iota
+	Write
+)
+
+func (k Kind) String() string {
+	if k == Read {
+		return "reads"
+	}
+	return "writes"
+}
+
+// remainingHeaders have how many requests are left on
+// the subscription quota of each kind.
+var remainingHeaders = map[Kind]string{
+	Read:  "x-ms-ratelimit-remaining-subscription-reads",
+	Write: "x-ms-ratelimit-remaining-subscription-writes",
+}
+
+// Subscription is the limiter shared by all tests, with the
+// default Azure Resource Manager quotas of an hour.
+var Subscription = New(12000, 1200, time.Hour)
+
+// Limiter is a token bucket for each kind of request, refilling
+// the quota over the given interval. The buckets follow the
+// remaining quota reported by Azure, since the subscription is
+// also used by other tools and people.
+type Limiter struct {
+	mutex   sync.Mutex
+	buckets map[Kind]*bucket
+	waits   map[string]time.Duration
+}
+
+type bucket struct {
+	tokens   float64
+	capacity float64
+	reserve  float64
+	// rate is how many tokens are added per second
+	rate float64
+	last time.Time
+}
+
+// New creates a limiter allowing the given reads and writes
+// on each interval.
+func New(reads int, writes int, interval time.Duration) *Limiter {
+	now := time.Now()
+	newBucket := func(quota int) *bucket {
+		return &bucket{
+			tokens:   float64(quota),
+			capacity: float64(quota),
+			// WHY: leaves some quota for anyone else
+			// using the subscription while tests run.
+			reserve: float64(quota) / 10,
+			rate:    float64(quota) / interval.Seconds(),
+			last:    now,
+		}
+	}
+	return &Limiter{
+		buckets: map[Kind]*bucket{
+			Read:  newBucket(reads),
+			Write: newBucket(writes),
+		},
+		waits: map[string]time.Duration{},
+	}
+}
+
+// Wait blocks until a request of the given kind is allowed or
+// the context is cancelled. The time spent waiting is accounted
+// to the given test.
+func (l *Limiter) Wait(ctx context.Context, test string, kind Kind) error {
+	start := time.Now()
+	defer func() {
+		l.mutex.Lock()
+		l.waits[test] += time.Since(start)
+		l.mutex.Unlock()
+	}()
+
+	for {
+		delay := l.take(kind)
+		if delay == 0 {
+			return nil
+		}
+		// WHY: time.After would keep its timer until it fires
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("waiting on %s rate limit: %s", kind, ctx.Err())
+		}
+	}
+}
+
+// take takes a token of the given kind, returning how long
+// to wait before trying again if there is none.
+func (l *Limiter) take(kind Kind) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b := l.buckets[kind]
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// Observe adapts the limiter to the remaining quota
+// reported on the headers of the given response.
+func (l *Limiter) Observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for kind, header := range remainingHeaders {
+		remaining, err := strconv.Atoi(resp.Header.Get(header))
+		if err != nil {
+			continue
+		}
+		b := l.buckets[kind]
+		b.refill(time.Now())
+		available := float64(remaining) - b.reserve
+		if available < b.tokens {
+			b.tokens = available
+		}
+	}
+}
+
+// Waited returns how long the given test waited on the limiter.
+func (l *Limiter) Waited(test string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.waits[test]
+}
+
+// Sender returns an autorest sender that waits on the limiter
+// before sending each request, accounting the wait to the
+// given test, and adapts the limiter to the responses.
+func (l *Limiter) Sender(ctx context.Context, test string) autorest.Sender {
+	sender := autorest.CreateSender()
+	return autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+		kind := Write
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			kind = Read
+		}
+		err := l.Wait(ctx, test, kind)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := sender.Do(req)
+		l.Observe(resp)
+		return resp, err
+	})
+}
+
+// Summary writes how long each test waited on the
+// limiter, the tests that waited longer first.
+func (l *Limiter) Summary(w io.Writer) error {
+	l.mutex.Lock()
+	tests := []string{}
+	var total time.Duration
+	for test, waited := range l.waits {
+		if waited >= time.Second {
+			tests = append(tests, test)
+		}
+		total += waited
+	}
+	waits := map[string]time.Duration{}
+	for test, waited := range l.waits {
+		waits[test] = waited
+	}
+	l.mutex.Unlock()
+
+	sort.Slice(tests, func(i, j int) bool {
+		return waits[tests[i]] > waits[tests[j]]
+	})
+
+	fmt.Fprintf(w, "rate limit: tests waited %s in total\n", total.Round(time.Second))
+	if len(tests) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "\ntest\twaited")
+	for _, test := range tests {
+		fmt.Fprintf(tw, "%s\t%s\n", test, waits[test].Round(time.Second))
+	}
+	return tw.Flush()
+}
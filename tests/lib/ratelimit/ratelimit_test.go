@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	type TestCase struct {
+		name  string
+		quota int
+		// takes is how many tokens are taken before
+		// the one that may wait, quotas are per hour.
+		takes int
+		wait  time.Duration
+	}
+
+	tests := []TestCase{
+		{
+			name:  "WithinQuota",
+			quota: 2,
+			takes: 1,
+			wait:  0,
+		},
+		{
+			name:  "ExactQuota",
+			quota: 2,
+			takes: 2,
+			wait:  30 * time.Minute,
+		},
+		{
+			name:  "OverQuota",
+			quota: 2,
+			takes: 3,
+			wait:  30 * time.Minute,
+		},
+		{
+			name:  "NoQuota",
+			quota: 1,
+			takes: 1,
+			wait:  time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := New(test.quota, test.quota, time.Hour)
+			for i := 0; i < test.takes; i++ {
+				limiter.take(Write)
+			}
+			wait := limiter.take(Write)
+			if test.wait == 0 && wait != 0 {
+				t.Fatalf("expected no wait, got[%s]", wait)
+			}
+			// WHY: the bucket refills a little between takes
+			if math.Abs(float64(wait-test.wait)) > float64(time.Second) {
+				t.Errorf("expected wait[%s], got[%s]", test.wait, wait)
+			}
+		})
+	}
+}
+
+func TestObserve(t *testing.T) {
+	type TestCase struct {
+		name   string
+		resp   *http.Response
+		reads  float64
+		writes float64
+	}
+
+	response := func(headers map[string]string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		for name, value := range headers {
+			resp.Header.Set(name, value)
+		}
+		return resp
+	}
+
+	tests := []TestCase{
+		{
+			name:   "NoResponse",
+			resp:   nil,
+			reads:  100,
+			writes: 10,
+		},
+		{
+			name:   "NoHeaders",
+			resp:   response(nil),
+			reads:  100,
+			writes: 10,
+		},
+		{
+			name: "LessRemaining",
+			resp: response(map[string]string{
+				"x-ms-ratelimit-remaining-subscription-reads":  "50",
+				"x-ms-ratelimit-remaining-subscription-writes": "5",
+			}),
+			reads:  40,
+			writes: 4,
+		},
+		{
+			name: "MoreRemaining",
+			resp: response(map[string]string{
+				"x-ms-ratelimit-remaining-subscription-reads": "11999",
+			}),
+			reads:  100,
+			writes: 10,
+		},
+		{
+			name: "InvalidHeader",
+			resp: response(map[string]string{
+				"x-ms-ratelimit-remaining-subscription-writes": "many",
+			}),
+			reads:  100,
+			writes: 10,
+		},
+		{
+			name: "ReserveExhausted",
+			resp: response(map[string]string{
+				"x-ms-ratelimit-remaining-subscription-writes": "0",
+			}),
+			reads:  100,
+			writes: -1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := New(100, 10, time.Hour)
+			limiter.Observe(test.resp)
+
+			for kind, want := range map[Kind]float64{Read: test.reads, Write: test.writes} {
+				got := limiter.buckets[kind].tokens
+				if math.Abs(got-want) > 0.1 {
+					t.Errorf("expected %s[%.1f], got[%.1f]", kind, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	limiter := New(1, 1, time.Hour)
+	limiter.take(Read)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := limiter.Wait(ctx, "TestWaitCancelled", Read)
+	if err == nil {
+		t.Fatal("expected error waiting without quota")
+	}
+	if limiter.Waited("TestWaitCancelled") < 50*time.Millisecond {
+		t.Errorf("expected wait accounted to the test, got[%s]", limiter.Waited("TestWaitCancelled"))
+	}
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// htmlTemplate is self contained, so the report can be
+// downloaded from CI and opened anywhere.
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>klb integration tests</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+tr.failed td.status { color: #b00; font-weight: bold; }
+tr.passed td.status { color: #070; }
+pre { margin: 0; white-space: pre-wrap; max-height: 20em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>klb integration tests</h1>
+<p>{{.Total}} tests, {{.Failed}} failed, generated at {{.Generated}}</p>
+
+{{if .Kept}}
+<h2>Resource groups kept</h2>
+<ul>
+{{range .Kept}}<li>{{.ResGroup}} ({{.Name}})</li>
+{{end}}
+</ul>
+{{end}}
+
+{{if .Undeleted}}
+<h2>Resource groups not confirmed deleted</h2>
+<ul>
+{{range .Undeleted}}<li>{{.ResGroup}} ({{.Name}})</li>
+{{end}}
+</ul>
+{{end}}
+
+<h2>Tests</h2>
+<table>
+<tr><th>status</th><th>test</th><th>duration</th><th>location</th><th>resgroup</th><th>retries</th><th>logs</th><th>failure</th></tr>
+{{range .Tests}}
+<tr class="{{if .Failed}}failed{{else}}passed{{end}}">
+<td class="status">{{if .Failed}}FAIL{{else}}PASS{{end}}</td>
+<td>{{.Name}}</td>
+<td>{{.Duration}}</td>
+<td>{{.Location}}</td>
+<td>{{.ResGroup}}</td>
+<td>{{.Retries}}</td>
+<td>{{if .LogsLink}}<a href="{{.LogsLink}}">logs</a>{{end}}</td>
+<td>{{if .Failed}}{{range .Failures}}<pre>{{.}}</pre>{{end}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+type htmlTest struct {
+	entry
+	Duration time.Duration
+	LogsLink string
+}
+
+type htmlReport struct {
+	Generated string
+	Total     int
+	Failed    int
+	Undeleted []entry
+	Kept      []entry
+	Tests     []htmlTest
+}
+
+func saveHTML(path string, entries []entry) error {
+	report := htmlReport{
+		Generated: time.Now().Format(time.RFC1123),
+		Total:     len(entries),
+		Undeleted: undeleted(entries),
+		Kept:      kept(entries),
+	}
+	for _, e := range entries {
+		if e.Failed {
+			report.Failed++
+		}
+		report.Tests = append(report.Tests, htmlTest{
+			entry:    e,
+			Duration: e.Duration.Round(time.Second),
+			LogsLink: logsLink(path, e.LogsPath),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, report)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// logsLink returns the path of the logs relative to the report.
+func logsLink(reportpath string, logspath string) string {
+	if logspath == "" {
+		return ""
+	}
+	reportdir, err := filepath.Abs(filepath.Dir(reportpath))
+	if err != nil {
+		return logspath
+	}
+	abslogspath, err := filepath.Abs(logspath)
+	if err != nil {
+		return logspath
+	}
+	link, err := filepath.Rel(reportdir, abslogspath)
+	if err != nil {
+		return logspath
+	}
+	return filepath.ToSlash(link)
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName  string          `xml:"classname,attr"`
+	Name       string          `xml:"name,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// saveJUnit writes the entries with a test suite for each top
+// level test, like TestVM, with its fixture tests as test cases.
+func saveJUnit(path string, entries []entry) error {
+	suites := junitSuites{}
+	index := map[string]int{}
+	for _, e := range entries {
+		classname, name := splitName(e.Name)
+		i, ok := index[classname]
+		if !ok {
+			i = len(suites.Suites)
+			index[classname] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: classname})
+		}
+		suite := &suites.Suites[i]
+		suite.Tests++
+
+		testcase := junitTestCase{
+			ClassName: classname,
+			Name:      name,
+			Time:      seconds(e.Duration),
+			Properties: []junitProperty{
+				{Name: "location", Value: e.Location},
+				{Name: "resgroup", Value: e.ResGroup},
+				{Name: "retries", Value: fmt.Sprint(e.Retries)},
+				{Name: "resgroup_deleted", Value: fmt.Sprint(e.Deleted)},
+				{Name: "resgroup_kept", Value: fmt.Sprint(e.Kept)},
+				{Name: "logs", Value: e.LogsPath},
+			},
+			SystemOut: fmt.Sprintf(
+				"location: %s\nresgroup: %s\nretries: %d\nlogs: %s\n",
+				e.Location,
+				e.ResGroup,
+				e.Retries,
+				e.LogsPath,
+			),
+		}
+		if e.Failed {
+			suite.Failures++
+			testcase.Failure = &junitFailure{
+				Message: firstLine(e.Failures[0]),
+				Text:    strings.Join(e.Failures, "\n\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, testcase)
+	}
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		var start, end time.Time
+		for _, e := range entries {
+			if classname, _ := splitName(e.Name); classname != suite.Name {
+				continue
+			}
+			// WHY: fixture tests run in parallel, so the suite takes
+			// from the first start to the last end of its tests.
+			if start.IsZero() || e.Start.Before(start) {
+				start = e.Start
+			}
+			if e.Start.Add(e.Duration).After(end) {
+				end = e.Start.Add(e.Duration)
+			}
+		}
+		suite.Timestamp = start.Format(time.RFC3339)
+		suite.Time = seconds(end.Sub(start))
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+// splitName splits a test name like "TestVM/VMCreation"
+// on the top level test and the rest.
+func splitName(name string) (string, string) {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 {
+		return name, name
+	}
+	return parts[0], parts[1]
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
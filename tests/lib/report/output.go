@@ -0,0 +1,136 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// testLine matches the lines go test writes when it starts,
+// resumes or finishes a test, the output after them belongs
+// to the test. Go 1.10 writes the output of each test after
+// its result line, newer versions may also write it after
+// the RUN, CONT and NAME lines when running with -v.
+var testLine = regexp.MustCompile(`^\s*(?:=== (?:RUN|CONT|NAME)|--- (?:FAIL|PASS|SKIP):)\s+(\S+)`)
+
+// output has the messages written by each test, like
+// the ones from t.Log and t.Fatalf.
+type output struct {
+	mutex    sync.Mutex
+	current  string
+	messages map[string][]string
+}
+
+var captured = &output{messages: map[string][]string{}}
+
+// Capture tees the go test output written on os.Stdout, keeping the
+// messages of each test so the failures of tests calling t.Fatalf
+// directly are on the reports too. It must be called on TestMain
+// before m.Run, and the returned function must be called after
+// m.Run and before Save, restoring os.Stdout.
+//
+// The output goes through a tee process, that outlives the tests,
+// so nothing is lost when a test panics or go test -timeout
+// kills the run before os.Stdout is restored.
+func Capture() (func() error, error) {
+	file, err := ioutil.TempFile("", "klb-tests-output")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	file.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	stdout := os.Stdout
+	cmd := exec.Command("tee", path)
+	cmd.Stdin = r
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	// WHY: only tee reads the output, it gets EOF when w is closed
+	r.Close()
+	if err != nil {
+		w.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("starting tee: %s", err)
+	}
+	os.Stdout = w
+
+	return func() error {
+		os.Stdout = stdout
+		w.Close()
+		defer os.Remove(path)
+
+		err := cmd.Wait()
+		if err != nil {
+			return fmt.Errorf("tee failed: %s", err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		captured.parse(file)
+		return nil
+	}, nil
+}
+
+// parse parses the go test output until the end of r.
+func (o *output) parse(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		o.parseLine(scanner.Text())
+	}
+}
+
+func (o *output) parseLine(line string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if match := testLine.FindStringSubmatch(line); match != nil {
+		o.current = match[1]
+		return
+	}
+	if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+		// WHY: unindented lines, like FAIL or ok, are not from tests
+		o.current = ""
+		return
+	}
+	msg := strings.TrimSpace(line)
+	if o.current == "" || msg == "" {
+		return
+	}
+	o.messages[o.current] = append(o.messages[o.current], msg)
+}
+
+// of returns the messages of the given test and of its subtests.
+func (o *output) of(test string) []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	subtests := []string{}
+	for name := range o.messages {
+		if strings.HasPrefix(name, test+"/") {
+			subtests = append(subtests, name)
+		}
+	}
+	sort.Strings(subtests)
+
+	res := append([]string{}, o.messages[test]...)
+	for _, name := range subtests {
+		res = append(res, o.messages[name]...)
+	}
+	return res
+}
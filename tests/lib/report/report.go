@@ -0,0 +1,133 @@
+// Package report generates JUnit XML and HTML reports of the
+// fixture tests, with the details needed to understand failures
+// of long running parallel tests without going through go test output.
+package report
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/telemetry"
+)
+
+// Test is a fixture test.
+type Test struct {
+	// Name is the full test name, like "TestVM/VMCreation".
+	Name     string
+	Location string
+	ResGroup string
+	Start    time.Time
+	Duration time.Duration
+	Failed   bool
+	// LogsPath is the path of the test logs, empty
+	// if logs are not saved on files.
+	LogsPath string
+	// Deleted is false if the resource group could
+	// not be confirmed deleted.
+	Deleted bool
+	// Kept is true if the resource group was kept
+	// on purpose, to debug the test.
+	Kept bool
+}
+
+var tests struct {
+	sync.Mutex
+	all []Test
+}
+
+// Record records the given test to be reported, it is safe
+// to be called concurrently.
+func Record(test Test) {
+	tests.Lock()
+	defer tests.Unlock()
+	tests.all = append(tests.all, test)
+}
+
+// entry is a test with the details from telemetry.
+type entry struct {
+	Test
+	Retries  int
+	Failures []string
+}
+
+// failedWithoutDetails is the failure of tests that did not fail
+// on an operation and whose output was not captured.
+const failedWithoutDetails = "test failed, see the logs for details"
+
+// entries returns all tests sorted by name with the retries, the
+// captured output and failed operations of each one, including
+// its subtests.
+func entries() []entry {
+	tests.Lock()
+	all := append([]Test{}, tests.all...)
+	tests.Unlock()
+
+	operations := telemetry.Operations()
+	res := []entry{}
+	for _, test := range all {
+		e := entry{Test: test}
+		for _, op := range operations {
+			if op.Test != test.Name && !strings.HasPrefix(op.Test, test.Name+"/") {
+				continue
+			}
+			e.Retries += op.Attempts - 1
+			if !op.Success {
+				e.Failures = append(e.Failures, strings.TrimSpace(op.Error))
+			}
+		}
+		if messages := captured.of(test.Name); test.Failed && len(messages) > 0 {
+			// WHY: the test output has what made it fail, like
+			// t.Fatalf messages, operation errors only add details.
+			e.Failures = append([]string{strings.Join(messages, "\n")}, e.Failures...)
+		}
+		if test.Failed && len(e.Failures) == 0 {
+			e.Failures = []string{failedWithoutDetails}
+		}
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// undeleted returns the resource groups that could
+// not be confirmed deleted, ignoring the kept ones.
+func undeleted(entries []entry) []entry {
+	res := []entry{}
+	for _, e := range entries {
+		if !e.Deleted && !e.Kept {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// kept returns the resource groups kept to debug tests.
+func kept(entries []entry) []entry {
+	res := []entry{}
+	for _, e := range entries {
+		if e.Kept {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Save writes the JUnit XML report on junitpath and the HTML
+// report on htmlpath, empty paths are skipped.
+func Save(junitpath string, htmlpath string) error {
+	all := entries()
+	if junitpath != "" {
+		err := saveJUnit(junitpath, all)
+		if err != nil {
+			return err
+		}
+	}
+	if htmlpath != "" {
+		return saveHTML(htmlpath, all)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package report
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOutput(t *testing.T) {
+	type TestCase struct {
+		name   string
+		output string
+		test   string
+		want   []string
+	}
+
+	tests := []TestCase{
+		{
+			name: "Go110",
+			output: `--- FAIL: TestNIC (0.00s)
+    --- FAIL: TestNIC/NICCreation (12.00s)
+    	nic_test.go:42: nic not found
+    	nic_test.go:50: expected[10.0.0.4], got[]
+    --- PASS: TestNIC/NICLoadBalancer (10.00s)
+    	nic_test.go:80: created
+FAIL
+`,
+			test: "TestNIC/NICCreation",
+			want: []string{
+				"nic_test.go:42: nic not found",
+				"nic_test.go:50: expected[10.0.0.4], got[]",
+			},
+		},
+		{
+			name: "Verbose",
+			output: `=== RUN   TestNIC
+=== RUN   TestNIC/NICCreation
+=== PAUSE TestNIC/NICCreation
+=== CONT  TestNIC/NICCreation
+    nic_test.go:42: nic not found
+=== NAME  TestNIC/NICLoadBalancer
+    nic_test.go:80: created
+=== CONT  TestNIC/NICCreation
+    nic_test.go:50: expected[10.0.0.4], got[]
+--- FAIL: TestNIC (22.00s)
+    --- FAIL: TestNIC/NICCreation (12.00s)
+FAIL
+`,
+			test: "TestNIC/NICCreation",
+			want: []string{
+				"nic_test.go:42: nic not found",
+				"nic_test.go:50: expected[10.0.0.4], got[]",
+			},
+		},
+		{
+			name: "Subtests",
+			output: `--- FAIL: TestVM (0.00s)
+    --- FAIL: TestVM/VMCreation (12.00s)
+    	vm_test.go:20: vm not created
+        --- FAIL: TestVM/VMCreation/Disks (1.00s)
+        	vm_test.go:10: disk not attached
+`,
+			test: "TestVM/VMCreation",
+			want: []string{
+				"vm_test.go:20: vm not created",
+				"vm_test.go:10: disk not attached",
+			},
+		},
+		{
+			name: "NotFromTests",
+			output: `--- FAIL: TestNIC/NICCreation (12.00s)
+rate limit: tests waited 0s in total
+    not a test message
+`,
+			test: "TestNIC/NICCreation",
+			want: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := &output{messages: map[string][]string{}}
+			o.parse(strings.NewReader(test.output))
+			got := o.of(test.test)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected[%q], got[%q]", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSaveJUnit(t *testing.T) {
+	start := time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC)
+	entries := []entry{
+		{
+			Test: Test{
+				Name:     "TestNIC/NICCreation",
+				Location: "eastus2",
+				ResGroup: "klb-NICCreation-1",
+				Start:    start,
+				Duration: 10 * time.Second,
+				Failed:   true,
+			},
+			Retries:  2,
+			Failures: []string{"nic_test.go:42: nic not found\nmore details"},
+		},
+		{
+			Test: Test{
+				Name:     "TestNIC/NICLoadBalancer",
+				Start:    start.Add(5 * time.Second),
+				Duration: 20 * time.Second,
+				Deleted:  true,
+			},
+		},
+		{
+			Test: Test{
+				Name:     "TestVM/VMCreation",
+				Start:    start,
+				Duration: time.Minute,
+				Deleted:  true,
+			},
+		},
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.xml")
+	err := saveJUnit(path, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got junitSuites
+	err = xml.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Suites) != 2 {
+		t.Fatalf("expected a suite for each top level test, got: %+v", got.Suites)
+	}
+	nic := got.Suites[0]
+	if nic.Name != "TestNIC" || nic.Tests != 2 || nic.Failures != 1 {
+		t.Errorf("unexpected TestNIC suite: %+v", nic)
+	}
+	// WHY: parallel tests take from the first start to the last end
+	if nic.Time != "25.000" {
+		t.Errorf("expected suite time[25.000], got[%s]", nic.Time)
+	}
+	failure := nic.Cases[0].Failure
+	if failure == nil || failure.Message != "nic_test.go:42: nic not found" {
+		t.Errorf("expected failure with the first line as message, got: %+v", failure)
+	}
+	if nic.Cases[1].Failure != nil {
+		t.Errorf("unexpected failure on passed test: %+v", nic.Cases[1].Failure)
+	}
+}
+
+func TestSaveHTML(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	entries := []entry{
+		{
+			Test: Test{
+				Name:     "TestNIC/NICCreation",
+				ResGroup: "klb-NICCreation-1",
+				Failed:   true,
+				LogsPath: filepath.Join(dir, "logs", "latest", "NICCreation.log"),
+			},
+			Failures: []string{"nic <not> found"},
+		},
+		{
+			Test: Test{
+				Name:     "TestNIC/NICKept",
+				ResGroup: "klb-NICKept-1",
+				Failed:   true,
+				Kept:     true,
+			},
+			Failures: []string{failedWithoutDetails},
+		},
+	}
+
+	path := filepath.Join(dir, "report.html")
+	err := saveHTML(path, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+
+	for _, want := range []string{
+		"2 tests, 2 failed",
+		"<h2>Resource groups kept</h2>\n<ul>\n<li>klb-NICKept-1 (TestNIC/NICKept)</li>",
+		"<h2>Resource groups not confirmed deleted</h2>\n<ul>\n<li>klb-NICCreation-1 (TestNIC/NICCreation)</li>",
+		`<a href="logs/latest/NICCreation.log">logs</a>`,
+		"<pre>nic &lt;not&gt; found</pre>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected[%s] on report:\n%s", want, html)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "klb-tests-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
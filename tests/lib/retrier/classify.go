@@ -0,0 +1,273 @@
+package retrier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Class tells how the retrier should react to an error.
+type Class int
+
+const (
+	// Transient errors are tried again using the policy backoff.
+	Transient Class = iota
+	// Throttled errors are tried again after the time Azure asks for.
+	Throttled
+	// Permanent errors will not go away trying again,
+	// the retrier gives up right away.
+	Permanent
+)
+
+func (c Class) String() string {
+	switch c {
+	case Transient:
+		return "transient"
+	case Throttled:
+		return "throttled"
+	case Permanent:
+		return "permanent"
+	}
+	return fmt.Sprintf("class(%d)", int(c))
+}
+
+// Classification is the result of classifying an error.
+type Classification struct {
+	Class Class
+	// RetryAfter is how long to wait before trying again,
+	// 0 means using the policy backoff.
+	RetryAfter time.Duration
+	// Reason explains the classification on logs and errors.
+	Reason string
+}
+
+// Classifier classifies an error, returning false when it
+// does not know the error.
+type Classifier func(err error) (Classification, bool)
+
+// Classifiers combines the given classifiers, the first one that
+// knows the error wins. Unknown errors are Transient, so they
+// get tried again like before classification existed. Errors
+// wrapped by Abort are always Permanent and errors wrapped by
+// Retry are never Permanent.
+func Classifiers(classifiers ...Classifier) Classifier {
+	var classify Classifier
+	classify = func(err error) (Classification, bool) {
+		if _, ok := err.(*aborted); ok {
+			return Classification{Class: Permanent, Reason: err.Error()}, true
+		}
+		if wrapped, ok := err.(*retriable); ok {
+			c, _ := classify(wrapped.err)
+			if c.Class == Permanent {
+				c.Class = Transient
+			}
+			return c, true
+		}
+		for _, classifier := range classifiers {
+			if classifier == nil {
+				continue
+			}
+			if c, ok := classifier(err); ok {
+				return c, true
+			}
+		}
+		return Classification{Class: Transient, Reason: "unknown error"}, true
+	}
+	return classify
+}
+
+// ScriptError is implemented by errors of failed scripts,
+// like nash.ExecError, allowing them to be classified by exit
+// code and output without the retrier depending on nash.
+type ScriptError interface {
+	error
+	// ScriptFailure returns the exit code and the last output lines.
+	ScriptFailure() (int, []string)
+}
+
+// ScriptRule classifies script failures matching
+// both its exit code and output.
+type ScriptRule struct {
+	// ExitCode to match, 0 matches any exit code.
+	ExitCode int
+	// Output is matched against each output line, nil matches any output.
+	Output *regexp.Regexp
+	Classification
+}
+
+func (r ScriptRule) match(exitcode int, output []string) bool {
+	if r.ExitCode != 0 && r.ExitCode != exitcode {
+		return false
+	}
+	if r.Output == nil {
+		return true
+	}
+	for _, line := range output {
+		if r.Output.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScriptClassifier classifies script failures using the given
+// rules, the first matching rule wins. Errors that are not
+// ScriptError or match no rule are unknown.
+func ScriptClassifier(rules ...ScriptRule) Classifier {
+	return func(err error) (Classification, bool) {
+		scripterr, ok := err.(ScriptError)
+		if !ok {
+			return Classification{}, false
+		}
+		exitcode, output := scripterr.ScriptFailure()
+		for _, rule := range rules {
+			if rule.match(exitcode, output) {
+				return rule.Classification, true
+			}
+		}
+		return Classification{}, false
+	}
+}
+
+var (
+	tryAgainAfter = regexp.MustCompile(`try again after '(\d+)' (second|minute|hour)s?`)
+	statusCode    = regexp.MustCompile(`Status(?:Code)?=(\d{3})\b`)
+	cliError      = regexp.MustCompile(`^(?:ERROR|error):`)
+)
+
+// throttlingMarkers are found on Azure throttling errors
+// that may not tell how long to wait.
+var throttlingMarkers = []string{
+	"TooManyRequests",
+	"exceeded the limit of",
+}
+
+// permanentCodes are Azure error codes that will not go
+// away trying again, with the SDK or the az/azure CLIs.
+var permanentCodes = []string{
+	"InvalidParameter",
+	"InvalidResourceName",
+	"InvalidTemplate",
+	"SkuNotAvailable",
+	"ResourceGroupNotFound",
+	"ResourceNotFound",
+	"AuthorizationFailed",
+}
+
+// notFoundCodes are Azure error codes of resources
+// that do not exist, or are not visible yet.
+var notFoundCodes = []string{
+	"ResourceGroupNotFound",
+	"ResourceNotFound",
+}
+
+// Azure classifies errors returned by the Azure SDK and CLIs.
+// Throttling errors are tried again after the time Azure asks
+// for, 5xx and 409 (conflict) are transient and the other 4xx,
+// like 400, 403 and 404, are permanent.
+//
+// Only the final error is classified, for scripts that is the
+// last error printed by az or azure, since klb scripts print
+// errors like ResourceNotFound when checking if resources exist.
+func Azure(err error) (Classification, bool) {
+	msg := finalError(err)
+	if msg == "" {
+		return Classification{}, false
+	}
+
+	if match := tryAgainAfter.FindStringSubmatch(msg); match != nil {
+		amount, _ := strconv.Atoi(match[1])
+		unit := map[string]time.Duration{
+			"second": time.Second,
+			"minute": time.Minute,
+			"hour":   time.Hour,
+		}[match[2]]
+		return Classification{
+			Class:      Throttled,
+			RetryAfter: time.Duration(amount) * unit,
+			Reason:     match[0],
+		}, true
+	}
+	for _, marker := range throttlingMarkers {
+		if strings.Contains(msg, marker) {
+			return Classification{Class: Throttled, Reason: marker}, true
+		}
+	}
+
+	if match := statusCode.FindStringSubmatch(msg); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		switch {
+		case code == 429:
+			return Classification{Class: Throttled, Reason: match[0]}, true
+		case code == 409 || code >= 500:
+			return Classification{Class: Transient, Reason: match[0]}, true
+		case code >= 400:
+			return Classification{Class: Permanent, Reason: match[0]}, true
+		}
+	}
+
+	for _, code := range permanentCodes {
+		if strings.Contains(msg, code) {
+			return Classification{Class: Permanent, Reason: code}, true
+		}
+	}
+	return Classification{}, false
+}
+
+// Polling classifies errors of asserts polling resources that are
+// eventually consistent, where not found errors are transient since
+// the resource may not be visible yet. Other errors are classified
+// by Azure.
+func Polling(err error) (Classification, bool) {
+	c, ok := Azure(err)
+	if !ok || c.Class != Permanent {
+		return c, ok
+	}
+	msg := finalError(err)
+	if match := statusCode.FindStringSubmatch(msg); match != nil && match[1] == "404" {
+		return Classification{Class: Transient, Reason: match[0]}, true
+	}
+	for _, code := range notFoundCodes {
+		if strings.Contains(msg, code) {
+			return Classification{Class: Transient, Reason: code}, true
+		}
+	}
+	return c, ok
+}
+
+// finalError returns the message of the error that made the work
+// fail. For scripts it is the last az or azure error on the output,
+// or empty when there is none, like when the script calls exit.
+func finalError(err error) string {
+	scripterr, ok := err.(ScriptError)
+	if !ok {
+		return err.Error()
+	}
+	_, output := scripterr.ScriptFailure()
+	for i := len(output) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(output[i])
+		if cliError.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
+
+// RolePropagation classifies authorization errors as transient,
+// since role assignments take a while to propagate. Azure aborts
+// on them, so only work that runs right after assigning roles
+// should use it, combined with Azure:
+//
+//	r.SetClassifier(retrier.Classifiers(retrier.RolePropagation, retrier.Azure))
+func RolePropagation(err error) (Classification, bool) {
+	msg := finalError(err)
+	if match := statusCode.FindStringSubmatch(msg); match != nil && match[1] == "403" {
+		return Classification{Class: Transient, Reason: match[0]}, true
+	}
+	if strings.Contains(msg, "AuthorizationFailed") {
+		return Classification{Class: Transient, Reason: "AuthorizationFailed"}, true
+	}
+	return Classification{}, false
+}
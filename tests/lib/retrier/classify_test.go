@@ -0,0 +1,318 @@
+package retrier
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type scriptError struct {
+	exitcode int
+	output   []string
+}
+
+func (e scriptError) Error() string {
+	return "script failed"
+}
+
+func (e scriptError) ScriptFailure() (int, []string) {
+	return e.exitcode, e.output
+}
+
+func TestAzureClassifier(t *testing.T) {
+	type TestCase struct {
+		name       string
+		err        error
+		known      bool
+		class      Class
+		retryAfter time.Duration
+	}
+
+	tests := []TestCase{
+		{
+			name:       "TryAgainAfter",
+			err:        errors.New("exceeded the limit of '1200' for time interval '01:00:00'. Please try again after '5' minutes"),
+			known:      true,
+			class:      Throttled,
+			retryAfter: 5 * time.Minute,
+		},
+		{
+			name:  "TooManyRequests",
+			err:   errors.New("Code=\"TooManyRequests\""),
+			known: true,
+			class: Throttled,
+		},
+		{
+			name:  "Status429",
+			err:   errors.New("autorest: StatusCode=429"),
+			known: true,
+			class: Throttled,
+		},
+		{
+			name:  "Status500",
+			err:   errors.New("autorest: StatusCode=500"),
+			known: true,
+			class: Transient,
+		},
+		{
+			name:  "Status409",
+			err:   errors.New("autorest: StatusCode=409"),
+			known: true,
+			class: Transient,
+		},
+		{
+			name:  "Status403",
+			err:   errors.New("autorest: StatusCode=403 Code=\"AuthorizationFailed\""),
+			known: true,
+			class: Permanent,
+		},
+		{
+			name:  "Status404",
+			err:   errors.New("autorest: StatusCode=404 Code=\"ResourceNotFound\""),
+			known: true,
+			class: Permanent,
+		},
+		{
+			name:  "InvalidParameter",
+			err:   errors.New("Code=\"InvalidParameter\""),
+			known: true,
+			class: Permanent,
+		},
+		{
+			name:  "Unknown",
+			err:   errors.New("connection reset by peer"),
+			known: false,
+		},
+		{
+			name: "ScriptLastCLIError",
+			err: scriptError{exitcode: 1, output: []string{
+				"ERROR: Code: ResourceNotFound",
+				"creating vm",
+				"ERROR: Operation failed with status: 'Internal Server Error'. StatusCode=500",
+				"exiting",
+			}},
+			known: true,
+			class: Transient,
+		},
+		{
+			name: "ScriptExistenceCheckIgnored",
+			err: scriptError{exitcode: 1, output: []string{
+				"ERROR: Code: ResourceNotFound",
+				"creating vm",
+				"ERROR: connection reset by peer",
+			}},
+			known: false,
+		},
+		{
+			name: "ScriptAzureCLIError",
+			err: scriptError{exitcode: 1, output: []string{
+				"error:   InvalidParameter: the vm size is invalid",
+			}},
+			known: true,
+			class: Permanent,
+		},
+		{
+			name: "ScriptAuthorizationFailed",
+			err: scriptError{exitcode: 1, output: []string{
+				"ERROR: The client 'klb' does not have authorization. Code: AuthorizationFailed",
+			}},
+			known: true,
+			class: Permanent,
+		},
+		{
+			name:  "ScriptWithoutCLIError",
+			err:   scriptError{exitcode: 1, output: []string{"ResourceNotFound", "exit 1"}},
+			known: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, ok := Azure(test.err)
+			if ok != test.known {
+				t.Fatalf("expected known[%t], got[%t]: %+v", test.known, ok, c)
+			}
+			if !ok {
+				return
+			}
+			if c.Class != test.class {
+				t.Errorf("expected class[%s], got[%s]", test.class, c.Class)
+			}
+			if c.RetryAfter != test.retryAfter {
+				t.Errorf("expected retry after[%s], got[%s]", test.retryAfter, c.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestPollingClassifier(t *testing.T) {
+	type TestCase struct {
+		name  string
+		err   error
+		class Class
+	}
+
+	tests := []TestCase{
+		{
+			name:  "Status404",
+			err:   errors.New("autorest: StatusCode=404"),
+			class: Transient,
+		},
+		{
+			name:  "ResourceGroupNotFound",
+			err:   errors.New("Code=\"ResourceGroupNotFound\""),
+			class: Transient,
+		},
+		{
+			name:  "ScriptResourceNotFound",
+			err:   scriptError{exitcode: 1, output: []string{"ERROR: Code: ResourceNotFound"}},
+			class: Transient,
+		},
+		{
+			name:  "Status403",
+			err:   errors.New("autorest: StatusCode=403"),
+			class: Permanent,
+		},
+		{
+			name:  "Status400",
+			err:   errors.New("autorest: StatusCode=400 Code=\"InvalidParameter\""),
+			class: Permanent,
+		},
+		{
+			name:  "Throttled",
+			err:   errors.New("autorest: StatusCode=429"),
+			class: Throttled,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, ok := Polling(test.err)
+			if !ok {
+				t.Fatal("expected error to be known")
+			}
+			if c.Class != test.class {
+				t.Errorf("expected class[%s], got[%s]", test.class, c.Class)
+			}
+		})
+	}
+}
+
+func TestRolePropagationClassifier(t *testing.T) {
+	type TestCase struct {
+		name  string
+		err   error
+		class Class
+	}
+
+	tests := []TestCase{
+		{
+			name:  "Status403",
+			err:   errors.New("autorest: StatusCode=403"),
+			class: Transient,
+		},
+		{
+			name: "ScriptAuthorizationFailed",
+			err: scriptError{exitcode: 1, output: []string{
+				"ERROR: The client 'klb' does not have authorization. Code: AuthorizationFailed",
+			}},
+			class: Transient,
+		},
+		{
+			name:  "Status400",
+			err:   errors.New("autorest: StatusCode=400 Code=\"InvalidParameter\""),
+			class: Permanent,
+		},
+	}
+
+	classify := Classifiers(RolePropagation, Azure)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := classify(test.err)
+			if c.Class != test.class {
+				t.Errorf("expected class[%s], got[%s]", test.class, c.Class)
+			}
+		})
+	}
+}
+
+func TestScriptClassifier(t *testing.T) {
+	classify := Classifiers(
+		ScriptClassifier(
+			ScriptRule{
+				ExitCode:       3,
+				Classification: Classification{Class: Permanent, Reason: "exit 3"},
+			},
+			ScriptRule{
+				Output:         regexp.MustCompile("quota"),
+				Classification: Classification{Class: Throttled, Reason: "quota"},
+			},
+		),
+		Azure,
+	)
+
+	type TestCase struct {
+		name   string
+		err    error
+		class  Class
+		reason string
+	}
+
+	tests := []TestCase{
+		{
+			name:   "ExitCode",
+			err:    scriptError{exitcode: 3},
+			class:  Permanent,
+			reason: "exit 3",
+		},
+		{
+			name:   "Output",
+			err:    scriptError{exitcode: 1, output: []string{"over quota"}},
+			class:  Throttled,
+			reason: "quota",
+		},
+		{
+			name:   "FallbackToAzure",
+			err:    scriptError{exitcode: 1, output: []string{"ERROR: StatusCode=400"}},
+			class:  Permanent,
+			reason: "StatusCode=400",
+		},
+		{
+			name:   "Unknown",
+			err:    errors.New("boom"),
+			class:  Transient,
+			reason: "unknown error",
+		},
+		{
+			name:   "Aborted",
+			err:    Abort(errors.New("StatusCode=500")),
+			class:  Permanent,
+			reason: "StatusCode=500",
+		},
+		{
+			name:   "RetryPermanent",
+			err:    Retry(errors.New("ERROR: StatusCode=400")),
+			class:  Transient,
+			reason: "StatusCode=400",
+		},
+		{
+			name:   "RetryThrottled",
+			err:    Retry(scriptError{exitcode: 1, output: []string{"over quota"}}),
+			class:  Throttled,
+			reason: "quota",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := classify(test.err)
+			if c.Class != test.class || c.Reason != test.reason {
+				t.Errorf(
+					"expected[%s: %s], got[%s: %s]",
+					test.class, test.reason, c.Class, c.Reason,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package retrier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attempt is a failed attempt of running some work.
+type Attempt struct {
+	// Number of the attempt, starting at 1.
+	Number int
+	// Start is when the attempt started.
+	Start time.Time
+	// Elapsed is how long the attempt took.
+	Elapsed time.Duration
+	// Err is the error returned by the work.
+	Err error
+	// Classification is how Err was classified.
+	Classification Classification
+}
+
+// Error is returned when the retrier gives up on some work,
+// it has the error of every attempt in order.
+type Error struct {
+	// Name of the work.
+	Name string
+	// Attempts are all the failed attempts in order.
+	Attempts []Attempt
+	// Reason is why the retrier gave up, like the context
+	// being cancelled or a permanent error.
+	Reason error
+}
+
+func (e *Error) Error() string {
+	errmsgs := []string{
+		"\n",
+		fmt.Sprintf("work %q failed, errors in order:", e.Name),
+	}
+	for i, attempt := range e.Attempts {
+		errmsgs = append(
+			errmsgs,
+			fmt.Sprintf(
+				"error[%d] at %s after %s: %s",
+				i,
+				attempt.Start.Format("15:04:05.000"),
+				attempt.Elapsed,
+				attempt.Err,
+			),
+		)
+	}
+	errmsgs = append(errmsgs, fmt.Sprintf("error[%d]: %s", len(e.Attempts), e.Reason))
+	return strings.Join(errmsgs, "\n")
+}
+
+// Errors returns the error of every attempt in order,
+// followed by the reason the retrier gave up.
+func (e *Error) Errors() []error {
+	errs := []error{}
+	for _, attempt := range e.Attempts {
+		errs = append(errs, attempt.Err)
+	}
+	return append(errs, e.Reason)
+}
+
+// aborted is an error that will not go away trying again.
+type aborted struct {
+	err error
+}
+
+func (e *aborted) Error() string {
+	return e.err.Error()
+}
+
+// Abort wraps err so the retrier gives up right away, whatever the
+// classifier says, like when a test replaying a cassette runs a
+// command that is not on it.
+func Abort(err error) error {
+	return &aborted{err: err}
+}
+
+// retriable is an error that may go away trying again.
+type retriable struct {
+	err error
+}
+
+func (e *retriable) Error() string {
+	return e.err.Error()
+}
+
+// Retry wraps err so the retrier tries again until the context is
+// cancelled or the policy attempts are exhausted, whatever the
+// classifier says, like errors of asserts probing resources that
+// may take a while to be ready. Throttling is still honored.
+func Retry(err error) error {
+	return &retriable{err: err}
+}
@@ -0,0 +1,74 @@
+package retrier
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy defines how work is tried again.
+type Policy struct {
+	// InitialBackoff is how long to wait before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps how long to wait between attempts.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each attempt,
+	// values smaller than 1 keep the backoff constant.
+	Multiplier float64
+	// Jitter randomizes each backoff up to this fraction
+	// of it, 0.2 means up to 20% more or less.
+	Jitter float64
+	// MaxAttempts caps the number of attempts, 0 means no limit.
+	MaxAttempts int
+	// AttemptTimeout is the timeout of each attempt, 0 means no limit.
+	AttemptTimeout time.Duration
+}
+
+// Default tries again every 10 seconds until the context gets
+// cancelled, since some Azure errors like throttling take a
+// while to go away.
+var Default = Policy{
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     1,
+}
+
+// Fast is meant for fast operations like reads and asserts,
+// that should converge in seconds.
+var Fast = Policy{
+	InitialBackoff: 1 * time.Second,
+	MaxBackoff:     15 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	AttemptTimeout: 2 * time.Minute,
+}
+
+// Slow is meant for long running operations, like creating a VM,
+// avoiding hammering Azure when they fail.
+var Slow = Policy{
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     2 * time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff returns how long to wait after the given attempt, that starts at 1.
+func (p Policy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// exhausted checks if no attempts are left after the given attempt.
+func (p Policy) exhausted(attempt int) bool {
+	return p.MaxAttempts > 0 && attempt >= p.MaxAttempts
+}
@@ -0,0 +1,74 @@
+package retrier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyBackoff(t *testing.T) {
+	type TestCase struct {
+		name     string
+		policy   Policy
+		attempts []time.Duration
+	}
+
+	tests := []TestCase{
+		{
+			name:     "Constant",
+			policy:   Default,
+			attempts: []time.Duration{10 * time.Second, 10 * time.Second, 10 * time.Second},
+		},
+		{
+			name: "Exponential",
+			policy: Policy{
+				InitialBackoff: time.Second,
+				MaxBackoff:     5 * time.Second,
+				Multiplier:     2,
+			},
+			attempts: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second},
+		},
+		{
+			name: "MultiplierBelowOne",
+			policy: Policy{
+				InitialBackoff: time.Second,
+				Multiplier:     0.5,
+			},
+			attempts: []time.Duration{time.Second, time.Second},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, want := range test.attempts {
+				got := test.policy.backoff(i + 1)
+				if got != want {
+					t.Errorf("attempt %d: expected backoff[%s], got[%s]", i+1, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyJitter(t *testing.T) {
+	policy := Policy{InitialBackoff: 10 * time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		got := policy.backoff(1)
+		if got < 8*time.Second || got > 12*time.Second {
+			t.Fatalf("backoff[%s] out of the jitter range", got)
+		}
+	}
+}
+
+func TestPolicyExhausted(t *testing.T) {
+	unlimited := Policy{}
+	if unlimited.exhausted(1000) {
+		t.Error("policy without MaxAttempts should never be exhausted")
+	}
+	limited := Policy{MaxAttempts: 3}
+	if limited.exhausted(2) {
+		t.Error("policy exhausted before MaxAttempts")
+	}
+	if !limited.exhausted(3) {
+		t.Error("policy not exhausted after MaxAttempts")
+	}
+}
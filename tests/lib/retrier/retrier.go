@@ -8,39 +8,58 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"strings"
 	"testing"
 	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/telemetry"
 )
 
 type Retrier struct {
-	ctx      context.Context
-	t        *testing.T
-	l        *log.Logger
-	disabled bool
+	ctx        context.Context
+	t          *testing.T
+	l          *testlog.Logger
+	policy     Policy
+	classifier Classifier
+	disabled   bool
 }
 
 type WorkFunc func() error
 
+// ContextWorkFunc is work that stops when the given context
+// is cancelled, like when an attempt timeouts.
+type ContextWorkFunc func(ctx context.Context) error
+
 // New creates a new Retrier instance.
 // The given context is used to model cancellation
-// of any operation on this retrier
+// of any operation on this retrier and the policy
+// defines how operations are tried again, like Fast or Slow.
+// The testing.T is only used by Run, so Go tools that only
+// use RunE can pass nil.
 func New(
 	ctx context.Context,
 	t *testing.T,
-	l *log.Logger,
+	l *testlog.Logger,
+	policy Policy,
 ) *Retrier {
 	return &Retrier{
-		ctx:      ctx,
-		t:        t,
-		l:        l,
-		disabled: false,
+		ctx:        ctx,
+		t:          t,
+		l:          l,
+		policy:     policy,
+		classifier: Azure,
+		disabled:   false,
 	}
 }
 
+// SetClassifier changes how errors are classified, the default
+// is Azure. Unknown errors are always tried again.
+func (r *Retrier) SetClassifier(classifier Classifier) {
+	r.classifier = classifier
+}
+
 // Disable will disable the retrier, if an operation fails once it
-// will abort the test with a fatal.
+// will abort the test with a fatal (or return the error on RunE).
 // This is used for debug purposes only, not a good idea to commit
 // code with this.
 func (r *Retrier) Disable() {
@@ -49,82 +68,189 @@ func (r *Retrier) Disable() {
 
 // Run executes the given work function trying again if something
 // goes wrong. On success it just returns, if the context gets
-// cancelled it will call testing.T.Fatal with all the accumulated errors.
+// cancelled, the policy attempts are exhausted or a permanent error
+// happens it will call testing.T.Fatal with all the accumulated errors.
 // The name parameter is used to aid the error messages.
+//
+// The work has no way to know about cancellation, so the attempt
+// timeout of the policy is not applied and each attempt runs until
+// the work returns. Prefer RunContext when the work can stop on
+// cancellation.
 func (r *Retrier) Run(
 	name string,
 	work WorkFunc,
 ) {
-	r.l.Printf("retrier: starting work %q", name)
+	policy := r.policy
+	policy.AttemptTimeout = 0
+	err := r.run(r.ctx, name, policy, func(context.Context) error {
+		return work()
+	})
+	r.fatal(err)
+}
+
+// RunContext executes the given work function just like Run, but
+// giving it the context of each attempt, that is cancelled when the
+// attempt timeouts or when the retrier context is cancelled.
+func (r *Retrier) RunContext(
+	name string,
+	work ContextWorkFunc,
+) {
+	err := r.run(r.ctx, name, r.policy, work)
+	r.fatal(err)
+}
+
+// RunE executes the given work function trying again if something
+// goes wrong, just like RunContext, but returning an *Error with
+// every attempt instead of failing the test.
+//
+// Each attempt gets a context that is cancelled when the attempt
+// timeouts or when the given context is cancelled. Attempts never
+// overlap, the next one only starts after the previous one returns,
+// so the work must return promptly when its context is cancelled.
+func (r *Retrier) RunE(
+	ctx context.Context,
+	name string,
+	work ContextWorkFunc,
+) error {
+	return r.run(ctx, name, r.policy, work)
+}
+
+func (r *Retrier) fatal(err error) {
+	if err != nil {
+		r.t.Fatal(testlog.Redact(err.Error()))
+	}
+}
+
+func (r *Retrier) run(
+	ctx context.Context,
+	name string,
+	policy Policy,
+	work ContextWorkFunc,
+) error {
+	l := r.l.With(testlog.Fields{Op: name})
+	l.Printf("retrier: starting work %q", name)
 	if r.disabled {
-		r.l.Println("retrier: disabled, running work once only")
-		err := work()
-		if err == nil {
-			return
-		}
-		r.t.Fatalf("retrier: error[%q] running[%q]", err, name)
-		return
+		l.Println("retrier: disabled, running work once only")
+		policy.MaxAttempts = 1
 	}
-	errs := retryUntilDone(r.ctx, r.l, name, work)
+	start := time.Now()
+	failed, reason := retryUntilDone(ctx, l, policy, Classifiers(r.classifier), name, work)
+	if reason != nil {
+		err := &Error{Name: name, Attempts: failed, Reason: reason}
+		r.record(name, start, failed, err)
+		return err
+	}
+	r.record(name, start, failed, nil)
+	l.Printf("retrier: success running work %q", name)
+	return nil
+}
 
-	if len(errs) == 0 {
-		r.l.Printf("retrier: success running work %q", name)
-		return
+// record sends the outcome of the work to telemetry.
+func (r *Retrier) record(name string, start time.Time, failed []Attempt, err *Error) {
+	op := telemetry.Operation{
+		ID:       name,
+		Attempts: len(failed),
+		Success:  err == nil,
+		Latency:  time.Since(start),
+		Errors:   map[string]int{},
 	}
-	errmsgs := []string{
-		"\n",
-		fmt.Sprintf("work %q failed, errors in order:", name),
+	if err != nil {
+		op.Error = testlog.Redact(err.Error())
 	}
-	for i, err := range errs {
-		errmsgs = append(
-			errmsgs,
-			fmt.Sprintf("error[%d]: %s", i, err),
-		)
+	if r.t != nil {
+		op.Test = r.t.Name()
 	}
-	r.t.Fatalf(strings.Join(errmsgs, "\n"))
+	if op.Success {
+		op.Attempts++
+	}
+	for _, attempt := range failed {
+		class := attempt.Classification
+		op.Errors[fmt.Sprintf("%s: %s", class.Class, class.Reason)]++
+	}
+	telemetry.RecordOperation(op)
 }
 
-const backoff = 10 * time.Second
-
 func retryUntilDone(
 	ctx context.Context,
-	l *log.Logger,
+	l *testlog.Logger,
+	policy Policy,
+	classify Classifier,
 	name string,
-	work WorkFunc,
-) []error {
-	var errs []error
-	for {
-		// WHY: buffered channel avoid goroutine leak
-		result := make(chan error, 1)
+	work ContextWorkFunc,
+) ([]Attempt, error) {
+	var failed []Attempt
+	for attempt := 1; ; attempt++ {
+		attemptLogger := l.With(testlog.Fields{Attempt: attempt})
+		attemptLogger.Printf("executing work: %s, attempt %d", name, attempt)
+		start := time.Now()
+		err := runAttempt(ctx, policy, work)
+		if err == nil {
+			return failed, nil
+		}
 
-		go func() {
-			l.Printf("executing work: %s", name)
-			result <- work()
-		}()
+		attemptLogger.Printf("%s: got error: %s", name, err)
+		class, _ := classify(err)
+		failed = append(failed, Attempt{
+			Number:         attempt,
+			Start:          start,
+			Elapsed:        time.Since(start),
+			Err:            err,
+			Classification: class,
+		})
 
-		select {
-		case res := <-result:
-			{
-				if res == nil {
-					return nil
-				}
-				l.Printf("%s: got error: %s", name, res)
-				errs = append(errs, res)
-			}
-		case <-ctx.Done():
-			{
-				l.Printf("retrier: %s: timeouted, returning all errors", name)
-				return append(
-					errs,
-					errors.New("operation timeouted"),
-				)
-			}
+		if ctx.Err() != nil {
+			attemptLogger.Printf("retrier: %s: timeouted, returning all errors", name)
+			return failed, errors.New("operation timeouted")
+		}
+
+		attemptLogger.Printf("%s: error is %s: %s", name, class.Class, class.Reason)
+		if class.Class == Permanent {
+			return failed, fmt.Errorf("aborted, permanent error: %s", class.Reason)
+		}
+
+		if policy.exhausted(attempt) {
+			return failed, fmt.Errorf("gave up after %d attempts", attempt)
 		}
+
 		// Sometimes we geet an error like: Number of write requests
 		// for subscription 'x'
 		// exceeded the limit of '1200' for time interval '01:00:00'.
 		// Please try again after '5' minutes
-		// Before trying again lets backoff a little.
-		time.Sleep(backoff)
+		// When Azure tells how long to wait we honor it,
+		// otherwise lets backoff a little.
+		backoff := policy.backoff(attempt)
+		if class.RetryAfter > 0 {
+			backoff = class.RetryAfter
+		}
+		attemptLogger.Printf("%s: trying again in %s", name, backoff)
+		// WHY: time.After would keep its timer until it fires
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return failed, errors.New("operation timeouted")
+		}
+	}
+}
+
+// runAttempt runs the work once, cancelling it when the attempt
+// timeouts. It always waits for the work to return, work that
+// ignores its context runs until it is done.
+func runAttempt(
+	ctx context.Context,
+	policy Policy,
+	work ContextWorkFunc,
+) error {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	if policy.AttemptTimeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+	}
+	defer cancel()
+
+	err := work(attemptCtx)
+	if err != nil && attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return fmt.Errorf("attempt timeouted after %s: %s", policy.AttemptTimeout, err)
 	}
+	return err
 }
@@ -0,0 +1,233 @@
+package retrier
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+)
+
+func newTestRetrier(policy Policy) *Retrier {
+	logger := testlog.Wrap(log.New(ioutil.Discard, "", 0))
+	return New(context.Background(), nil, logger, policy)
+}
+
+func TestRunE(t *testing.T) {
+	type TestCase struct {
+		name       string
+		policy     Policy
+		classifier Classifier
+		errs       []error
+		attempts   int
+		reason     string
+	}
+
+	transient := errors.New("StatusCode=500")
+	permanent := errors.New("StatusCode=400")
+	quick := Policy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	limited := quick
+	limited.MaxAttempts = 2
+
+	tests := []TestCase{
+		{
+			name:     "Success",
+			policy:   quick,
+			errs:     []error{nil},
+			attempts: 1,
+		},
+		{
+			name:     "SuccessAfterTransient",
+			policy:   quick,
+			errs:     []error{transient, transient, nil},
+			attempts: 3,
+		},
+		{
+			name:     "Permanent",
+			policy:   quick,
+			errs:     []error{transient, permanent, nil},
+			attempts: 2,
+			reason:   "aborted, permanent error: StatusCode=400",
+		},
+		{
+			name:       "PermanentClassifiedTransient",
+			policy:     quick,
+			classifier: Polling,
+			errs:       []error{errors.New("StatusCode=404"), nil},
+			attempts:   2,
+		},
+		{
+			name:     "Aborted",
+			policy:   quick,
+			errs:     []error{Abort(transient), nil},
+			attempts: 1,
+			reason:   "aborted, permanent error: StatusCode=500",
+		},
+		{
+			name:     "Retry",
+			policy:   quick,
+			errs:     []error{Retry(permanent), Retry(permanent), nil},
+			attempts: 3,
+		},
+		{
+			name:     "Exhausted",
+			policy:   limited,
+			errs:     []error{transient, transient, nil},
+			attempts: 2,
+			reason:   "gave up after 2 attempts",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestRetrier(test.policy)
+			if test.classifier != nil {
+				r.SetClassifier(test.classifier)
+			}
+			attempts := 0
+			err := r.RunE(context.Background(), test.name, func(context.Context) error {
+				err := test.errs[attempts]
+				attempts++
+				return err
+			})
+			if attempts != test.attempts {
+				t.Errorf("expected %d attempts, got %d", test.attempts, attempts)
+			}
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			rerr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("expected *Error, got[%#v]", err)
+			}
+			if rerr.Reason.Error() != test.reason {
+				t.Errorf("expected reason[%s], got[%s]", test.reason, rerr.Reason)
+			}
+			if len(rerr.Attempts) != test.attempts {
+				t.Errorf("expected %d failed attempts, got %d", test.attempts, len(rerr.Attempts))
+			}
+		})
+	}
+}
+
+func TestRunECancelled(t *testing.T) {
+	r := newTestRetrier(Policy{InitialBackoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	err := r.RunE(ctx, "cancelled", func(context.Context) error {
+		cancel()
+		return errors.New("failed")
+	})
+	rerr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got[%#v]", err)
+	}
+	if rerr.Reason.Error() != "operation timeouted" {
+		t.Errorf("unexpected reason: %s", rerr.Reason)
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	policy := Policy{
+		InitialBackoff: time.Millisecond,
+		MaxAttempts:    2,
+		AttemptTimeout: 10 * time.Millisecond,
+	}
+
+	type TestCase struct {
+		name  string
+		work  ContextWorkFunc
+		error string
+	}
+
+	tests := []TestCase{
+		{
+			name: "WorkStopsOnTimeout",
+			work: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			error: "attempt timeouted after 10ms: context deadline exceeded",
+		},
+		{
+			name: "WorkIgnoresTimeout",
+			work: func(context.Context) error {
+				time.Sleep(50 * time.Millisecond)
+				return errors.New("failed")
+			},
+			error: "attempt timeouted after 10ms: failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestRetrier(policy)
+			running := int32(0)
+			start := time.Now()
+			err := r.RunE(context.Background(), test.name, func(ctx context.Context) error {
+				if atomic.AddInt32(&running, 1) > 1 {
+					t.Error("attempts overlapped")
+				}
+				defer atomic.AddInt32(&running, -1)
+				return test.work(ctx)
+			})
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("attempts did not timeout, took %s", elapsed)
+			}
+			rerr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("expected *Error, got[%#v]", err)
+			}
+			if len(rerr.Attempts) != 2 {
+				t.Fatalf("expected 2 attempts, got %d", len(rerr.Attempts))
+			}
+			got := rerr.Attempts[0].Err.Error()
+			if !strings.HasPrefix(got, test.error) {
+				t.Errorf("expected error starting with[%s], got[%s]", test.error, got)
+			}
+		})
+	}
+}
+
+func TestRunWaitsForWork(t *testing.T) {
+	policy := Policy{AttemptTimeout: 10 * time.Millisecond}
+	r := New(context.Background(), t, testlog.Wrap(log.New(ioutil.Discard, "", 0)), policy)
+
+	attempts := 0
+	done := false
+	r.Run("work", func() error {
+		attempts++
+		time.Sleep(50 * time.Millisecond)
+		done = true
+		return nil
+	})
+	if !done {
+		t.Error("Run returned before the work")
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRunContextAttemptTimeout(t *testing.T) {
+	policy := Policy{AttemptTimeout: time.Minute}
+	r := New(context.Background(), t, testlog.Wrap(log.New(ioutil.Discard, "", 0)), policy)
+
+	r.RunContext("work", func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("attempt context has no deadline")
+		}
+		if time.Until(deadline) > time.Minute {
+			t.Errorf("attempt deadline[%s] after the attempt timeout", deadline)
+		}
+		return nil
+	})
+}
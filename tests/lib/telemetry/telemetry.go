@@ -0,0 +1,311 @@
+// Package telemetry records how operations and scripts behave
+// during the integration tests, like how many attempts an
+// operation needed or how long a script takes, helping to
+// adjust timeouts and to find klb functions that need polling.
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Operation is a retried operation, identified by names
+// like "VM.AssertExists.name".
+type Operation struct {
+	ID   string
+	Test string
+	// Attempts is the number of attempts, including the last one.
+	Attempts int
+	Success  bool
+	Latency  time.Duration
+	// Errors counts the errors of each category,
+	// like "throttled: TooManyRequests".
+	Errors map[string]int
+	// Error is the final error of failed operations.
+	Error string
+}
+
+// Script is a single execution of a script or klb function.
+type Script struct {
+	// Name is the script file name or the module and function
+	// called, like "create_vm.sh" or "azure/vm:azure_vm_create".
+	Name     string
+	Test     string
+	Duration time.Duration
+	Success  bool
+}
+
+var records struct {
+	sync.Mutex
+	operations []Operation
+	scripts    []Script
+}
+
+// RecordOperation records the given operation, it is safe
+// to be called concurrently.
+func RecordOperation(op Operation) {
+	records.Lock()
+	defer records.Unlock()
+	records.operations = append(records.operations, op)
+}
+
+// RecordScript records the given script execution, it is safe
+// to be called concurrently.
+func RecordScript(script Script) {
+	records.Lock()
+	defer records.Unlock()
+	records.scripts = append(records.scripts, script)
+}
+
+// Operations returns all operations recorded so far.
+func Operations() []Operation {
+	records.Lock()
+	defer records.Unlock()
+	return append([]Operation{}, records.operations...)
+}
+
+// ScriptStats are the statistics of all executions of a script.
+type ScriptStats struct {
+	Name     string
+	Runs     int
+	Failures int
+	P50      time.Duration
+	P95      time.Duration
+	Max      time.Duration
+}
+
+// Save writes all records with the script statistics as JSON
+// on the given path and a human readable summary on w, an empty
+// path writes only the summary.
+func Save(path string, w io.Writer) error {
+	records.Lock()
+	operations := append([]Operation{}, records.operations...)
+	scripts := append([]Script{}, records.scripts...)
+	records.Unlock()
+
+	stats := scriptStats(scripts)
+	if path != "" {
+		err := saveJSON(path, operations, scripts, stats)
+		if err != nil {
+			return err
+		}
+	}
+	return writeSummary(w, operations, stats)
+}
+
+// summaryTop is how many operations, retried or failed,
+// are listed on the summary by number of attempts.
+const summaryTop = 10
+
+func writeSummary(w io.Writer, operations []Operation, stats []ScriptStats) error {
+	listed := []Operation{}
+	retried, failed := 0, 0
+	for _, op := range operations {
+		if op.Attempts > 1 {
+			retried++
+		}
+		if !op.Success {
+			failed++
+		}
+		if op.Attempts > 1 || !op.Success {
+			listed = append(listed, op)
+		}
+	}
+	sort.SliceStable(listed, func(i, j int) bool {
+		return listed[i].Attempts > listed[j].Attempts
+	})
+	if len(listed) > summaryTop {
+		listed = listed[:summaryTop]
+	}
+
+	fmt.Fprintf(
+		w,
+		"telemetry: %d operations, %d needed retries, %d failed\n",
+		len(operations),
+		retried,
+		failed,
+	)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if len(listed) > 0 {
+		fmt.Fprintln(tw, "\noperation\tattempts\ttest\tlatency\toutcome\terrors")
+		for _, op := range listed {
+			fmt.Fprintf(
+				tw,
+				"%s\t%d\t%s\t%s\t%s\t%s\n",
+				op.ID,
+				op.Attempts,
+				op.Test,
+				op.Latency.Round(time.Second),
+				outcome(op.Success),
+				categories(op.Errors),
+			)
+		}
+	}
+	if len(stats) > 0 {
+		fmt.Fprintln(tw, "\nscript\truns\tfailures\tp50\tp95\tmax")
+		for _, s := range stats {
+			fmt.Fprintf(
+				tw,
+				"%s\t%d\t%d\t%s\t%s\t%s\n",
+				s.Name,
+				s.Runs,
+				s.Failures,
+				s.P50.Round(time.Second),
+				s.P95.Round(time.Second),
+				s.Max.Round(time.Second),
+			)
+		}
+	}
+	return tw.Flush()
+}
+
+// scriptStats returns the statistics of each script,
+// sorted by the slowest p95 first.
+func scriptStats(scripts []Script) []ScriptStats {
+	durations := map[string][]time.Duration{}
+	failures := map[string]int{}
+	for _, s := range scripts {
+		durations[s.Name] = append(durations[s.Name], s.Duration)
+		if !s.Success {
+			failures[s.Name]++
+		}
+	}
+
+	stats := []ScriptStats{}
+	for name, d := range durations {
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		stats = append(stats, ScriptStats{
+			Name:     name,
+			Runs:     len(d),
+			Failures: failures[name],
+			P50:      percentile(d, 50),
+			P95:      percentile(d, 95),
+			Max:      d[len(d)-1],
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].P95 == stats[j].P95 {
+			return stats[i].Name < stats[j].Name
+		}
+		return stats[i].P95 > stats[j].P95
+	})
+	return stats
+}
+
+// percentile uses the nearest rank method on the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func outcome(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+func categories(errors map[string]int) string {
+	names := []string{}
+	for name := range errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := ""
+	for i, name := range names {
+		if i > 0 {
+			res += ", "
+		}
+		res += fmt.Sprintf("%s x%d", name, errors[name])
+	}
+	return res
+}
+
+type jsonOperation struct {
+	ID             string         `json:"id"`
+	Test           string         `json:"test"`
+	Attempts       int            `json:"attempts"`
+	Outcome        string         `json:"outcome"`
+	LatencySeconds float64        `json:"latency_seconds"`
+	Errors         map[string]int `json:"errors,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+type jsonScript struct {
+	Name            string  `json:"name"`
+	Test            string  `json:"test"`
+	Outcome         string  `json:"outcome"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type jsonScriptStats struct {
+	Name       string  `json:"name"`
+	Runs       int     `json:"runs"`
+	Failures   int     `json:"failures"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+	MaxSeconds float64 `json:"max_seconds"`
+}
+
+type jsonReport struct {
+	Operations  []jsonOperation   `json:"operations"`
+	Scripts     []jsonScript      `json:"scripts"`
+	ScriptStats []jsonScriptStats `json:"script_stats"`
+}
+
+func saveJSON(
+	path string,
+	operations []Operation,
+	scripts []Script,
+	stats []ScriptStats,
+) error {
+	report := jsonReport{
+		Operations:  []jsonOperation{},
+		Scripts:     []jsonScript{},
+		ScriptStats: []jsonScriptStats{},
+	}
+	for _, op := range operations {
+		report.Operations = append(report.Operations, jsonOperation{
+			ID:             op.ID,
+			Test:           op.Test,
+			Attempts:       op.Attempts,
+			Outcome:        outcome(op.Success),
+			LatencySeconds: op.Latency.Seconds(),
+			Errors:         op.Errors,
+			Error:          op.Error,
+		})
+	}
+	for _, s := range scripts {
+		report.Scripts = append(report.Scripts, jsonScript{
+			Name:            s.Name,
+			Test:            s.Test,
+			Outcome:         outcome(s.Success),
+			DurationSeconds: s.Duration.Seconds(),
+		})
+	}
+	for _, s := range stats {
+		report.ScriptStats = append(report.ScriptStats, jsonScriptStats{
+			Name:       s.Name,
+			Runs:       s.Runs,
+			Failures:   s.Failures,
+			P50Seconds: s.P50.Seconds(),
+			P95Seconds: s.P95.Seconds(),
+			MaxSeconds: s.Max.Seconds(),
+		})
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
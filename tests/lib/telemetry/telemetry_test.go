@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	type TestCase struct {
+		name   string
+		sorted []time.Duration
+		p      int
+		want   time.Duration
+	}
+
+	hundred := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		hundred = append(hundred, time.Duration(i)*time.Second)
+	}
+
+	tests := []TestCase{
+		{
+			name:   "Single",
+			sorted: []time.Duration{time.Second},
+			p:      95,
+			want:   time.Second,
+		},
+		{
+			name:   "MedianOdd",
+			sorted: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second},
+			p:      50,
+			want:   2 * time.Second,
+		},
+		{
+			name:   "MedianEven",
+			sorted: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+			p:      50,
+			want:   2 * time.Second,
+		},
+		{
+			name:   "P95Small",
+			sorted: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second},
+			p:      95,
+			want:   3 * time.Second,
+		},
+		{
+			name:   "P95Hundred",
+			sorted: hundred,
+			p:      95,
+			want:   95 * time.Second,
+		},
+		{
+			name:   "Zero",
+			sorted: hundred,
+			p:      0,
+			want:   time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := percentile(test.sorted, test.p)
+			if got != test.want {
+				t.Errorf("expected p%d[%s], got[%s]", test.p, test.want, got)
+			}
+		})
+	}
+}
+
+func TestScriptStats(t *testing.T) {
+	stats := scriptStats([]Script{
+		{Name: "create_vm.sh", Duration: 3 * time.Minute, Success: true},
+		{Name: "create_nic.sh", Duration: 10 * time.Second, Success: true},
+		{Name: "create_vm.sh", Duration: time.Minute, Success: false},
+		{Name: "create_vm.sh", Duration: 2 * time.Minute, Success: true},
+	})
+
+	want := []ScriptStats{
+		{
+			Name:     "create_vm.sh",
+			Runs:     3,
+			Failures: 1,
+			P50:      2 * time.Minute,
+			P95:      3 * time.Minute,
+			Max:      3 * time.Minute,
+		},
+		{
+			Name: "create_nic.sh",
+			Runs: 1,
+			P50:  10 * time.Second,
+			P95:  10 * time.Second,
+			Max:  10 * time.Second,
+		},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("expected %d stats, got %+v", len(want), stats)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("stats[%d]: expected %+v, got %+v", i, want[i], stats[i])
+		}
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	var out bytes.Buffer
+	err := writeSummary(&out, []Operation{
+		{ID: "VM.AssertExists:vm", Attempts: 1, Success: true},
+		{
+			ID:       "Nic.AssertExists:nic",
+			Attempts: 3,
+			Success:  true,
+			Errors:   map[string]int{"transient: StatusCode=404": 2},
+		},
+		{ID: "Disk.AssertExists:disk", Attempts: 5, Success: false},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := out.String()
+	if !strings.HasPrefix(summary, "telemetry: 3 operations, 2 needed retries, 1 failed\n") {
+		t.Errorf("unexpected summary header:\n%s", summary)
+	}
+	disk := strings.Index(summary, "Disk.AssertExists:disk")
+	nic := strings.Index(summary, "Nic.AssertExists:nic")
+	if disk < 0 || nic < 0 || disk > nic {
+		t.Errorf("expected retried operations by attempts, got:\n%s", summary)
+	}
+	if strings.Contains(summary, "VM.AssertExists:vm") {
+		t.Errorf("operations without retries must not be listed:\n%s", summary)
+	}
+	if !strings.Contains(summary, "transient: StatusCode=404 x2") {
+		t.Errorf("expected error categories, got:\n%s", summary)
+	}
+}
@@ -0,0 +1,166 @@
+package unit_test
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NeowayLabs/klb/tests/lib/assert"
+	"github.com/NeowayLabs/klb/tests/lib/golden"
+	testlog "github.com/NeowayLabs/klb/tests/lib/log"
+	"github.com/NeowayLabs/klb/tests/lib/nash"
+)
+
+// unitTimeout is the timeout of unit tests, they
+// don't touch the cloud so they should be fast.
+const unitTimeout = 5 * time.Minute
+
+func TestMain(m *testing.M) {
+	golden.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func newOffline(t *testing.T) (*nash.Shell, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), unitTimeout)
+	// WHY: unit tests log on the test output, they
+	// don't have the flags and log files of the fixture
+	logger := testlog.Wrap(log.New(testWriter{t}, "", log.Ltime))
+	return nash.NewOffline(ctx, t, logger), cancel
+}
+
+// testWriter writes on the test log.
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func TestUnitVMInstanceBuilders(t *testing.T) {
+	t.Parallel()
+
+	shell, teardown := newOffline(t)
+	defer teardown()
+
+	instance := shell.CallStrings("azure/vm", "azure_vm_new", "name", "group", "location")
+	instance = shell.CallStrings("azure/vm", "azure_vm_set_vmsize", instance, "Standard_DS4_v2")
+	instance = shell.CallStrings("azure/vm", "azure_vm_set_nics", instance, []string{"nic1", "nic2"})
+
+	assert.EqualStringSlices(t, []string{
+		"--name", "name",
+		"--resource-group", "group",
+		"--location", "location",
+		"--size", "Standard_DS4_v2",
+		"--nics", "nic1 nic2",
+	}, instance, "vm instance")
+
+	shell.Call("azure/vm", "azure_vm_create", instance)
+	shell.AssertCalls(
+		append([]string{"az", "vm", "create", "--output", "table"}, instance...),
+	)
+}
+
+func TestUnitVMBackupDatadiskLun(t *testing.T) {
+	t.Parallel()
+
+	shell, teardown := newOffline(t)
+	defer teardown()
+
+	lun := shell.CallString("azure/vm", "_azure_vm_backup_datadisk_lun", "datadisk-3")
+	assert.EqualStrings(t, "3", lun, "backup datadisk lun")
+
+	_, err := shell.CallOnce("azure/vm", "_azure_vm_backup_datadisk_lun", 1, "invalid")
+	if err == nil {
+		t.Fatal("expected error parsing invalid backup datadisk name")
+	}
+	shell.AssertCalls()
+}
+
+func TestUnitStorageFixRemotePath(t *testing.T) {
+	t.Parallel()
+
+	shell, teardown := newOffline(t)
+	defer teardown()
+
+	for remotepath, fixed := range map[string]string{
+		"/dir/file": "dir/file",
+		"dir/file":  "dir/file",
+		"/":         "",
+	} {
+		got := shell.CallString("azure/blob/fs", "_azure_storage_fix_remote_path", remotepath)
+		assert.EqualStrings(t, fixed, got, "fixed remote path of "+remotepath)
+	}
+}
+
+func TestUnitLBRuleCreate(t *testing.T) {
+	t.Parallel()
+
+	shell, teardown := newOffline(t)
+	defer teardown()
+
+	rule := shell.CallStrings("azure/lb", "azure_lb_rule_new", "rule", "group")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_lbname", rule, "lb")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_probename", rule, "probe")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_frontendipname", rule, "frontendip")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_frontendport", rule, "80")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_backendport", rule, "8080")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_protocol", rule, "Tcp")
+	rule = shell.CallStrings("azure/lb", "azure_lb_rule_set_backend_pool_name", rule, "pool")
+
+	shell.Fake(nash.Interaction{Args: []string{"az", "network", "lb", "rule", "create"}})
+	shell.Call("azure/lb", "azure_lb_rule_create", rule)
+	golden.New(t, "LBRuleCreate").AssertCommands("lb", shell.Calls())
+}
+
+func TestUnitStorageAccountKey(t *testing.T) {
+	t.Parallel()
+
+	shell, teardown := newOffline(t)
+	defer teardown()
+
+	shell.Fake(nash.Interaction{
+		Args: []string{"az", "storage", "account", "keys", "list"},
+		Stdout: `[
+			{"keyName": "key1", "value": "readkey", "permissions": "Read"},
+			{"keyName": "key2", "value": "fullkey", "permissions": "Full"}
+		]`,
+	})
+
+	res := shell.CallN("azure/storage", "_azure_storage_account_get_key_value", 2, "account", "group")
+	assert.EqualStringSlices(t, []string{"fullkey"}, res[0], "account key")
+	assert.EqualStringSlices(t, []string{""}, res[1], "account key error")
+
+	shell.AssertCalls([]string{
+		"az", "storage", "account", "keys", "list",
+		"-g", "group", "-n", "account", "--output", "json",
+	})
+}
+
+func TestUnitVMDatadisksIDsLun(t *testing.T) {
+	t.Parallel()
+
+	shell, teardown := newOffline(t)
+	defer teardown()
+
+	shell.Fake(nash.Interaction{
+		Args: []string{"az", "vm", "show"},
+		Stdout: `{"storageProfile": {"dataDisks": [
+			{"lun": 0, "managedDisk": {"id": "disk0"}},
+			{"lun": 3, "managedDisk": {"id": "disk3"}}
+		]}}`,
+	})
+
+	idsluns := shell.CallLists("azure/vm", "azure_vm_get_datadisks_ids_lun", "vm", "group")
+	if len(idsluns) != 2 {
+		t.Fatalf("expected 2 datadisks, got %q", idsluns)
+	}
+	assert.EqualStringSlices(t, []string{"disk0", "0"}, idsluns[0], "first datadisk")
+	assert.EqualStringSlices(t, []string{"disk3", "3"}, idsluns[1], "second datadisk")
+}
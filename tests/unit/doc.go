@@ -0,0 +1,4 @@
+// Package unit contains unit tests for klb nash code, az and
+// azure commands are faked so they run with plain go test,
+// without credentials and without touching the cloud.
+package unit
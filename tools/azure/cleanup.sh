@@ -6,6 +6,32 @@ import klb/azure/vm
 
 klbtests_prefix = "^klb-*"
 
+# expired returns "0" if the resource group can be deleted, "1" if
+# it was kept by the tests and its expires-at tag is in the future.
+# Groups without the tag, or that can't be read, can be deleted.
+fn expired(resgroup) {
+	expiresat, status <= (
+		az group show --name $resgroup |
+		jq -r --arg tag "expires-at" ".tags[$tag] // empty"
+	)
+	if $status != "0" {
+		return "0"
+	}
+	if $expiresat == "" {
+		return "0"
+	}
+
+	future, status <= echo $expiresat | jq -R "fromdateiso8601 > now"
+	if $status != "0" {
+		echo "ERROR: invalid expires-at["+$expiresat+"] on resgroup: "+$resgroup
+		return "1"
+	}
+	if $future == "true" {
+		return "1"
+	}
+	return "0"
+}
+
 echo
 echo "========================================================================="
 echo "this script will attempt to cleanup all pending klb tests resource groups"
@@ -18,15 +44,30 @@ echo "getting resource group names"
 resgroups <= azure_group_get_names()
 
 filtered = ()
+kept = ()
 
 for resgroup in $resgroups {
 	_, err <= echo $resgroup | grep $klbtests_prefix
 
 	if $err == "0" {
-		filtered <= append($filtered, $resgroup)
+		isexpired <= expired($resgroup)
+		if $isexpired == "0" {
+			filtered <= append($filtered, $resgroup)
+		} else {
+			kept <= append($kept, $resgroup)
+		}
 	}
 }
 
+echo
+echo "skipping the following resource groups, kept until their expires-at tag:"
+echo "==============================================="
+echo
+
+for resgroup in $kept {
+	echo $resgroup
+}
+
 echo
 echo "going to delete the following resource groups:"
 echo "==============================================="
@@ -39,14 +80,11 @@ for resgroup in $filtered {
 echo
 echo "==============================================="
 echo
-echo "from credentials:"
+echo "from subscription:"
 echo "==============================================="
 echo
+# WHY: the subscription, tenant and client IDs are credentials
 echo "AZURE_SUBSCRIPTION_NAME: "+$AZURE_SUBSCRIPTION_NAME
-echo "AZURE_SUBSCRIPTION_ID: "+$AZURE_SUBSCRIPTION_ID
-echo "AZURE_TENANT_ID: "+$AZURE_TENANT_ID
-echo "AZURE_CLIENT_ID: "+$AZURE_CLIENT_ID
-echo "AZURE_SERVICE_PRINCIPAL"+$AZURE_SERVICE_PRINCIPAL
 echo
 echo "==============================================="
 echo